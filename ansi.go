@@ -0,0 +1,331 @@
+package vt10x
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// gfxReverse maps the characters of the DEC line drawing set back to the
+// bytes selecting them.
+var gfxReverse = func() map[rune]byte {
+	m := make(map[rune]byte)
+	for i, c := range gfxCharTable {
+		if c != 0 && c != ' ' {
+			m[c] = byte(0x41 + i)
+		}
+	}
+	return m
+}()
+
+// privateModes are the DEC private modes restored by WriteANSI, other
+// than the alt screen, origin and cursor visibility modes.
+var privateModes = []struct {
+	mode ModeFlag
+	n    int
+}{
+	{ModeAppCursor, 1},
+	{ModeReverse, 5},
+	{ModeMouseX10, 9},
+	{ModeMouseButton, 1000},
+	{ModeMouseMotion, 1002},
+	{ModeMouseMany, 1003},
+	{ModeFocus, 1004},
+	{ModeMouseUtf8, 1005},
+	{ModeMouseSgr, 1006},
+	{ModeMouseUrxvt, 1015},
+	{Mode8bit, 1034},
+	{ModeBracketedPaste, 2004},
+}
+
+// ansiModes are the ANSI modes restored by WriteANSI.
+var ansiModes = []struct {
+	mode ModeFlag
+	n    int
+}{
+	{ModeKeyboardLock, 2},
+	{ModeInsert, 4},
+	{ModeEcho, 12},
+	{ModeCRLF, 20},
+}
+
+// ansiWriter tracks the pen of the terminal receiving the output of
+// WriteANSI, to only send the attributes that change.
+type ansiWriter struct {
+	*bufio.Writer
+	pen Glyph
+	gfx bool
+}
+
+// WriteANSI writes the escape sequences reproducing the screen in a newly
+// created terminal of the same size: the contents of both screens,
+// attributes, soft wraps, the cursor and its style, the scroll region,
+// tab stops, colors changed by OSC sequences, the title and the terminal
+// modes. The history is not included.
+func (t *State) WriteANSI(w io.Writer) error {
+	b := &ansiWriter{Writer: bufio.NewWriter(w)}
+	b.pen = t.defaultCursor().Attr
+	b.WriteString("\033[0m\033(B\033[H\033[2J")
+
+	alt := t.mode&ModeAltScreen != 0
+	if alt {
+		// draw the primary screen, then switch with 1049 to save the
+		// cursor of the primary screen
+		t.writeANSILines(b, t.altLines)
+		b.setPen(t.curSaved.Attr)
+		fmt.Fprintf(b, "\033[%d;%dH\033[?1049h", t.curSaved.y+1, t.curSaved.x+1)
+		t.writeANSILines(b, t.lines)
+	} else {
+		t.writeANSILines(b, t.lines)
+		if saved := t.curSaved; saved.Attr != t.defaultCursor().Attr || saved.x != 0 || saved.y != 0 {
+			b.setPen(saved.Attr)
+			fmt.Fprintf(b, "\033[%d;%dH\0337", saved.y+1, saved.x+1)
+		}
+	}
+
+	for i, c := range t.palette.colors {
+		if c != DefaultPalette[i] {
+			fmt.Fprintf(b, "\033]4;%d;%s\a", i, cssColor(c))
+		}
+	}
+	for _, c := range []struct {
+		d      int
+		c, def Color
+	}{
+		{10, t.palette.fg, DefaultPalette[LightGrey]},
+		{11, t.palette.bg, DefaultPalette[Black]},
+		{12, t.palette.cursorColor, DefaultFG},
+	} {
+		if c.c != c.def {
+			fmt.Fprintf(b, "\033]%d;%s\a", c.d, cssColor(t.ResolveColor(c.c)))
+		}
+	}
+	if t.title != "" {
+		fmt.Fprintf(b, "\033]0;%s\a", t.title)
+	}
+
+	var tabs strings.Builder
+	custom := false
+	for x, set := range t.tabs {
+		if set != (x > 0 && x%tabspaces == 0) {
+			custom = true
+		}
+		if set {
+			fmt.Fprintf(&tabs, "\033[1;%dH\033H", x+1)
+		}
+	}
+	if custom {
+		b.WriteString("\033[3g" + tabs.String())
+	}
+
+	if t.top != 0 || t.bottom != t.rows-1 {
+		fmt.Fprintf(b, "\033[%d;%dr", t.top+1, t.bottom+1)
+	}
+	origin := t.Cur.state&cursorOrigin != 0
+	if origin {
+		b.WriteString("\033[?6h")
+	}
+	t.writeANSICursor(b, origin)
+
+	if shape, blink := t.CursorStyle(); shape != CursorBlock || !blink {
+		n := 2*int(shape) + 1
+		if !blink {
+			n++
+		}
+		fmt.Fprintf(b, "\033[%d q", n)
+	}
+	for _, m := range privateModes {
+		if t.mode&m.mode != 0 {
+			fmt.Fprintf(b, "\033[?%dh", m.n)
+		}
+	}
+	for _, m := range ansiModes {
+		if t.mode&m.mode != 0 {
+			fmt.Fprintf(b, "\033[%dh", m.n)
+		}
+	}
+	if t.mode&ModeAppKeypad != 0 {
+		b.WriteString("\033=")
+	}
+	if t.mode&ModeWrap == 0 {
+		b.WriteString("\033[?7l")
+	}
+	if t.mode&ModeHide != 0 {
+		b.WriteString("\033[?25l")
+	}
+	return b.Flush()
+}
+
+// writeANSILines draws lines on a blank screen, continuing soft wrapped
+// rows by letting the terminal wrap.
+func (t *State) writeANSILines(b *ansiWriter, lines []Line) {
+	wrapped := false
+	for y, l := range lines {
+		n := len(l)
+		if l[n-1].Mode&attrWrap == 0 || y == len(lines)-1 {
+			for n > 0 && l[n-1] == blankGlyph {
+				n--
+			}
+		}
+		if n > 0 && !wrapped {
+			fmt.Fprintf(b, "\033[%d;1H", y+1)
+		}
+		for x := 0; x < n; x++ {
+			g := l[x]
+			if g.Mode&attrWDummy != 0 {
+				continue
+			}
+			// skip runs of blanks, except at the start of a wrapped row
+			if x > 0 && g == blankGlyph {
+				i := x
+				for i < n && l[i] == blankGlyph {
+					i++
+				}
+				if i-x > 3 {
+					fmt.Fprintf(b, "\033[%dC", i-x)
+					x = i - 1
+					continue
+				}
+			}
+			b.writeGlyph(g)
+		}
+		wrapped = l[len(l)-1].Mode&attrWrap != 0
+	}
+}
+
+// writeANSICursor moves to the cursor. A pending wrap is restored by
+// writing the last character of the row again.
+func (t *State) writeANSICursor(b *ansiWriter, origin bool) {
+	x, y := t.Cur.x, t.Cur.y
+	if origin {
+		y -= t.top
+	}
+	if t.Cur.state&cursorWrapNext != 0 && t.mode&ModeWrap != 0 {
+		l := t.lines[t.Cur.y]
+		lx := t.cols - 1
+		if l[lx].Mode&attrWDummy != 0 && lx > 0 {
+			lx--
+		}
+		if g := l[lx]; writable(g) {
+			fmt.Fprintf(b, "\033[%d;%dH", y+1, lx+1)
+			b.writeGlyph(g)
+			b.setPen(t.Cur.Attr)
+			return
+		}
+	}
+	b.setPen(t.Cur.Attr)
+	fmt.Fprintf(b, "\033[%d;%dH", y+1, x+1)
+}
+
+// writable returns true if writing the character of g with the pen
+// returned by penOf reproduces g. Cells cleared with a bold pen keep their
+// dark foreground, and are erased instead.
+func writable(g Glyph) bool {
+	return g.Mode&attrReverse != 0 || g.Mode&attrBold == 0 || g.Fg >= 8
+}
+
+// penOf returns the pen that setChar turns into g, undoing the swap of
+// reversed colors or the brightening of bold ones.
+func penOf(g Glyph) Glyph {
+	if g.Mode&attrReverse != 0 {
+		g.Fg, g.Bg = g.Bg, g.Fg
+	} else if g.Mode&attrBold != 0 && between(int(g.Fg), 8, 15) {
+		g.Fg -= 8
+	}
+	return g
+}
+
+// writeGlyph writes a cell and moves right.
+func (b *ansiWriter) writeGlyph(g Glyph) {
+	if !writable(g) {
+		b.setPen(g)
+		b.WriteString("\033[X\033[C")
+		return
+	}
+	b.setPen(penOf(g))
+	if g.Mode&attrGfx != 0 {
+		if c, ok := gfxReverse[g.Char]; ok {
+			b.WriteByte(c)
+			b.WriteString(g.Comb)
+			return
+		}
+	}
+	b.WriteString(g.String())
+}
+
+// setPen sends the attributes and character set of g if they differ from
+// the current ones.
+func (b *ansiWriter) setPen(g Glyph) {
+	if gfx := g.Mode&attrGfx != 0; gfx != b.gfx {
+		if gfx {
+			b.WriteString("\033(0")
+		} else {
+			b.WriteString("\033(B")
+		}
+		b.gfx = gfx
+	}
+	mode := g.Mode & int32(attrSGR)
+	if mode == b.pen.Mode&int32(attrSGR) && g.Fg == b.pen.Fg && g.Bg == b.pen.Bg && g.Ul == b.pen.Ul {
+		return
+	}
+	b.pen = g
+	b.WriteString(sgr(g))
+}
+
+// sgr returns the SGR sequence setting the attributes and colors of g from
+// the defaults.
+func sgr(g Glyph) string {
+	p := []string{"0"}
+	attrs := g.Attrs()
+	for _, a := range []struct {
+		attr Attr
+		p    string
+	}{
+		{AttrBold, "1"},
+		{AttrFaint, "2"},
+		{AttrItalic, "3"},
+		{AttrUnderline, "4"},
+		{AttrDoubleUnderline, "4:2"},
+		{AttrCurlyUnderline, "4:3"},
+		{AttrDottedUnderline, "4:4"},
+		{AttrDashedUnderline, "4:5"},
+		{AttrBlink, "5"},
+		{AttrReverse, "7"},
+		{AttrInvisible, "8"},
+		{AttrStrike, "9"},
+		{AttrOverline, "53"},
+	} {
+		if attrs&a.attr != 0 {
+			p = append(p, a.p)
+		}
+	}
+	if g.Fg != DefaultFG {
+		p = append(p, sgrColor(g.Fg, 30))
+	}
+	if g.Bg != DefaultBG {
+		p = append(p, sgrColor(g.Bg, 40))
+	}
+	if g.Ul != DefaultUL {
+		p = append(p, sgrColor(g.Ul, 50))
+	}
+	return "\033[" + strings.Join(p, ";") + "m"
+}
+
+// sgrColor returns the SGR parameters of color c, where base is 30 for the
+// foreground, 40 for the background and 50 for underlines.
+func sgrColor(c Color, base int) string {
+	switch {
+	case c.IsRGB():
+		r, g, b := c.RGB()
+		return fmt.Sprintf("%d;2;%d;%d;%d", base+8, r, g, b)
+	case c.ANSI() && base != 50:
+		if c < 8 {
+			return fmt.Sprint(base + int(c))
+		}
+		return fmt.Sprint(base + 60 + int(c) - 8)
+	case c.Palette():
+		return fmt.Sprintf("%d;5;%d", base+8, c)
+	}
+	return fmt.Sprint(base + 9)
+}
@@ -0,0 +1,78 @@
+package vt10x
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestWriteANSI(t *testing.T) {
+	tests := []string{
+		// attributes, colors, wide and combining characters
+		"\033[1;31mbold red\033[0m \033[7;38;5;100mrev\033[0m \033[4:3;58;2;1;2;3mcurly\033[0m\r\n" +
+			"世界 e\u0301 \033[1;7;32;45mx\033[0m \033(0lqqk\033(B\r\n" +
+			"0123456789012345678901234\r\n" +
+			"\033[1;31;44m\033[K\033[0m\033[3C\033[9Xab",
+		// palette, title, tabs, modes and cursor state
+		"\033]4;1;#102030\a\033]11;#000010\a\033]2;hello\a\033[3g\033[1;5H\033H\033[1;12H\033H" +
+			"\033[3;4H\0337\033[?1h\033[?1002h\033[?1006h\033[?2004h\033=\033[4h\033[?25l\033[6 q" +
+			"\033[2;5r\033[?6h\033[2;3H\033[4mx",
+		// pending wrap at the end of a row
+		"\033[2;1H01234567890123456789\033[33m",
+		"\033[?7lxyz",
+		// alt screen
+		"primary\r\nscreen\033[2;4H\033[?1049h\033[3;1Halt\033[41m\033[J\033[m",
+	}
+	for _, test := range tests {
+		var a, b State
+		ta, _ := Create(&a, nil)
+		tb, _ := Create(&b, nil)
+		ta.Resize(20, 6)
+		tb.Resize(20, 6)
+		ta.Write([]byte(test))
+
+		var buf bytes.Buffer
+		if err := a.WriteANSI(&buf); err != nil {
+			t.Fatal(err)
+		}
+		tb.Write(buf.Bytes())
+		if err := equalStates(&a, &b); err != "" {
+			t.Fatalf("%q: %s\noutput: %q", test, err, buf.String())
+		}
+	}
+}
+
+// equalStates compares what WriteANSI reproduces.
+func equalStates(a, b *State) string {
+	for _, l := range []struct {
+		name string
+		a, b []Line
+	}{{"lines", a.lines, b.lines}, {"alt lines", a.altLines, b.altLines}} {
+		for y := range l.a {
+			for x := range l.a[y] {
+				if ga, gb := l.a[y][x], l.b[y][x]; ga != gb {
+					return fmt.Sprintf("%s: cell (%d, %d) is %+v, expected %+v", l.name, x, y, gb, ga)
+				}
+			}
+		}
+	}
+	for _, f := range []struct {
+		name string
+		a, b interface{}
+	}{
+		{"cursor", []interface{}{a.Cur.x, a.Cur.y, a.Cur.state, a.Cur.Attr}, []interface{}{b.Cur.x, b.Cur.y, b.Cur.state, b.Cur.Attr}},
+		{"saved cursor", []interface{}{a.curSaved.x, a.curSaved.y, a.curSaved.Attr}, []interface{}{b.curSaved.x, b.curSaved.y, b.curSaved.Attr}},
+		{"mode", a.mode, b.mode},
+		{"scroll region", []int{a.top, a.bottom}, []int{b.top, b.bottom}},
+		{"title", a.title, b.title},
+		{"tabs", a.tabs, b.tabs},
+		{"palette", a.palette, b.palette},
+		{"cursor style", []interface{}{a.curShape, a.curSteady}, []interface{}{b.curShape, b.curSteady}},
+	} {
+		if !reflect.DeepEqual(f.a, f.b) {
+			return fmt.Sprintf("%s is %+v, expected %+v", f.name, f.b, f.a)
+		}
+	}
+	return ""
+}
@@ -1,5 +1,10 @@
 package vt10x
 
+import (
+	"strconv"
+	"strings"
+)
+
 // ANSI color values
 const (
 	Black Color = iota
@@ -23,15 +28,115 @@ const (
 // Default colors are potentially distinct to allow for special behavior.
 // For example, a transparent background. Otherwise, the simple case is to
 // map default colors to another color.
+// DefaultUL draws underlines in the foreground color.
 const (
 	DefaultFG Color = 0xff80 + iota
 	DefaultBG
+	DefaultUL
 )
 
+// rgbFlag marks a Color holding a 24-bit RGB value in its low bits.
+const rgbFlag Color = 1 << 24
+
 // Color maps to the ANSI colors [0, 16) and the xterm colors [16, 256).
-type Color uint16
+// Colors created with RGB carry a 24-bit value instead of a palette index.
+type Color uint32
+
+// RGB returns the Color for a 24-bit RGB value.
+func RGB(r, g, b uint8) Color {
+	return rgbFlag | Color(r)<<16 | Color(g)<<8 | Color(b)
+}
 
 // ANSI returns true if Color is within [0, 16).
 func (c Color) ANSI() bool {
 	return (c < 16)
 }
+
+// Palette returns true if Color is an index into the 256 color palette.
+func (c Color) Palette() bool {
+	return c < 256
+}
+
+// IsRGB returns true if Color holds a 24-bit RGB value.
+func (c Color) IsRGB() bool {
+	return c&rgbFlag != 0
+}
+
+// RGB returns the red, green and blue components of an RGB Color. It
+// returns zeros for palette and default colors.
+func (c Color) RGB() (r, g, b uint8) {
+	if !c.IsRGB() {
+		return 0, 0, 0
+	}
+	return uint8(c >> 16), uint8(c >> 8), uint8(c)
+}
+
+// DefaultPalette holds the RGB values of the 256 xterm colors.
+var DefaultPalette = func() [256]Color {
+	var p [256]Color
+	ansi := [16]uint32{
+		0x000000, 0xcd0000, 0x00cd00, 0xcdcd00,
+		0x0000ee, 0xcd00cd, 0x00cdcd, 0xe5e5e5,
+		0x7f7f7f, 0xff0000, 0x00ff00, 0xffff00,
+		0x5c5cff, 0xff00ff, 0x00ffff, 0xffffff,
+	}
+	for i, v := range ansi {
+		p[i] = rgbFlag | Color(v)
+	}
+	// 6x6x6 color cube
+	levels := [6]uint8{0, 0x5f, 0x87, 0xaf, 0xd7, 0xff}
+	for i := 0; i < 216; i++ {
+		p[16+i] = RGB(levels[i/36], levels[i/6%6], levels[i%6])
+	}
+	// grayscale ramp
+	for i := 0; i < 24; i++ {
+		v := uint8(8 + 10*i)
+		p[232+i] = RGB(v, v, v)
+	}
+	return p
+}()
+
+// parseColorSpec parses an X11 color specification as used by OSC color
+// sequences: rgb:r/g/b or #rgb, with 1 to 4 hex digits per component.
+func parseColorSpec(spec string) (Color, bool) {
+	var parts []string
+	scaled := true
+	switch {
+	case strings.HasPrefix(spec, "rgb:"):
+		parts = strings.Split(spec[4:], "/")
+	case strings.HasPrefix(spec, "#"):
+		n := len(spec) - 1
+		if n == 0 || n%3 != 0 || n > 12 {
+			return 0, false
+		}
+		n /= 3
+		for i := 1; i < len(spec); i += n {
+			parts = append(parts, spec[i:i+n])
+		}
+		scaled = false
+	}
+	if len(parts) != 3 {
+		return 0, false
+	}
+	var rgb [3]uint8
+	for i, p := range parts {
+		if len(p) < 1 || len(p) > 4 {
+			return 0, false
+		}
+		v, err := strconv.ParseUint(p, 16, 16)
+		if err != nil {
+			return 0, false
+		}
+		bits := uint(4 * len(p))
+		switch {
+		case scaled:
+			// rgb:h/h/h scales to the full range, so f is 0xff
+			rgb[i] = uint8(v * 0xff / (1<<bits - 1))
+		case bits > 8:
+			rgb[i] = uint8(v >> (bits - 8))
+		default:
+			rgb[i] = uint8(v << (8 - bits))
+		}
+	}
+	return RGB(rgb[0], rgb[1], rgb[2]), true
+}
@@ -0,0 +1,30 @@
+package vt10x
+
+import (
+	"testing"
+)
+
+func TestTrueColor(t *testing.T) {
+	var st State
+	term, err := Create(&st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	term.Write([]byte("\033[38;2;255;128;0;48;5;200ma\033[48;2;1;2;3mb\033[mc"))
+
+	_, fg, bg := st.Cell(0, 0)
+	if !fg.IsRGB() || fg.Palette() || bg != 200 || !bg.Palette() {
+		t.Fatalf("unexpected colors %x %x", fg, bg)
+	}
+	if r, g, b := fg.RGB(); r != 255 || g != 128 || b != 0 {
+		t.Fatalf("unexpected fg components %d %d %d", r, g, b)
+	}
+	_, fg, bg = st.Cell(1, 0)
+	if fg != RGB(255, 128, 0) || bg != RGB(1, 2, 3) {
+		t.Fatalf("unexpected colors %x %x", fg, bg)
+	}
+	_, fg, bg = st.Cell(2, 0)
+	if fg != DefaultFG || bg != DefaultBG || fg.IsRGB() {
+		t.Fatalf("unexpected colors %x %x", fg, bg)
+	}
+}
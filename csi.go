@@ -8,17 +8,27 @@ import (
 // CSI (Control Sequence Introducer)
 // ESC+[
 type csiEscape struct {
-	buf  []byte
-	args []int
-	mode byte
-	priv bool
+	buf   []byte
+	args  []int
+	empty []bool  // arguments left empty, which take their default
+	sub   [][]int // colon separated sub-parameters of each argument
+	mode  byte
+	inter string // intermediate bytes preceding the final byte
+	priv  bool
+	gt    bool
+	other byte // '=' or '<' prefix, unused by the handled sequences
 }
 
 func (c *csiEscape) reset() {
 	c.buf = c.buf[:0]
 	c.args = c.args[:0]
+	c.empty = c.empty[:0]
+	c.sub = c.sub[:0]
 	c.mode = 0
+	c.inter = ""
 	c.priv = false
+	c.gt = false
+	c.other = 0
 }
 
 func (c *csiEscape) put(b byte) bool {
@@ -37,24 +47,51 @@ func (c *csiEscape) parse() {
 	}
 	s := string(c.buf)
 	c.args = c.args[:0]
-	if s[0] == '?' {
+	c.empty = c.empty[:0]
+	c.sub = c.sub[:0]
+	switch s[0] {
+	case '?':
 		c.priv = true
 		s = s[1:]
+	case '>':
+		c.gt = true
+		s = s[1:]
+	case '=', '<':
+		c.other = s[0]
+		s = s[1:]
 	}
 	s = s[:len(s)-1]
+	i := len(s)
+	for i > 0 && between(int(s[i-1]), 0x20, 0x2f) {
+		i--
+	}
+	c.inter = s[i:]
+	s = s[:i]
 	ss := strings.Split(s, ";")
 	for _, p := range ss {
-		i, err := strconv.Atoi(p)
-		if err != nil {
-			//t.logf("invalid CSI arg '%s'\n", p)
-			break
+		// empty sub-parameters are zero, empty parameters are marked to
+		// take the default of the sequence
+		var sub []int
+		for _, f := range strings.Split(p, ":") {
+			var i int
+			if f != "" {
+				var err error
+				i, err = strconv.Atoi(f)
+				if err != nil {
+					//t.logf("invalid CSI arg '%s'\n", p)
+					return
+				}
+			}
+			sub = append(sub, i)
 		}
-		c.args = append(c.args, i)
+		c.args = append(c.args, sub[0])
+		c.empty = append(c.empty, p == "")
+		c.sub = append(c.sub, sub)
 	}
 }
 
 func (c *csiEscape) arg(i, def int) int {
-	if i >= len(c.args) || i < 0 {
+	if i >= len(c.args) || i < 0 || c.empty[i] {
 		return def
 	}
 	return c.args[i]
@@ -65,8 +102,17 @@ func (c *csiEscape) maxarg(i, def int) int {
 	return max(c.arg(i, def), def)
 }
 
+// Device attribute responses
+const (
+	primaryDA   = "\033[?6c"     // VT102
+	secondaryDA = "\033[>0;0;0c" // VT100, firmware version 0
+)
+
 func (t *State) handleCSI() {
 	c := &t.csi
+	if c.gt && c.mode != 'c' || c.inter != "" && c.mode != 'q' || c.other != 0 {
+		goto unknown
+	}
 	switch c.mode {
 	default:
 		goto unknown
@@ -78,7 +124,11 @@ func (t *State) handleCSI() {
 		t.moveTo(t.Cur.x, t.Cur.y+c.maxarg(0, 1))
 	case 'c': // DA - device attributes
 		if c.arg(0, 0) == 0 {
-			// TODO: write vt102 id
+			if c.gt {
+				t.reply(secondaryDA)
+			} else {
+				t.reply(primaryDA)
+			}
 		}
 	case 'C', 'a': // CUF, HPR - Cursor <n> forward
 		t.moveTo(t.Cur.x+c.maxarg(0, 1), t.Cur.y)
@@ -111,7 +161,6 @@ func (t *State) handleCSI() {
 			t.putTab(true)
 		}
 	case 'J': // ED - clear screen
-		// TODO: sel.ob.x = -1
 		switch c.arg(0, 0) {
 		case 0: // below
 			t.clear(t.Cur.x, t.Cur.y, t.cols-1, t.Cur.y)
@@ -125,6 +174,8 @@ func (t *State) handleCSI() {
 			t.clear(0, t.Cur.y, t.Cur.x, t.Cur.y)
 		case 2: // all
 			t.clear(0, 0, t.cols-1, t.rows-1)
+		case 3: // scrollback
+			t.history.reset()
 		default:
 			goto unknown
 		}
@@ -156,12 +207,29 @@ func (t *State) handleCSI() {
 		for i := 0; i < n; i++ {
 			t.putTab(false)
 		}
+	case 'n': // DSR - device status report
+		switch c.arg(0, 0) {
+		case 5: // operating status
+			t.reply("\033[0n")
+		case 6: // CPR - cursor position report
+			y := t.Cur.y
+			if t.Cur.state&cursorOrigin != 0 {
+				y -= t.top
+			}
+			if c.priv {
+				t.reply("\033[?%d;%dR", y+1, t.Cur.x+1)
+			} else {
+				t.reply("\033[%d;%dR", y+1, t.Cur.x+1)
+			}
+		default:
+			goto unknown
+		}
 	case 'd': // VPA - move to <row>
 		t.moveAbsTo(t.Cur.x, c.arg(0, 1)-1)
 	case 'h': // SM - set terminal Mode
 		t.setMode(c.priv, true, c.args)
 	case 'm': // SGR - terminal attribute (color)
-		t.setAttr(c.args)
+		t.setAttr(c.sub)
 	case 'r': // DECSTBM - set scrolling region
 		if c.priv {
 			goto unknown
@@ -173,21 +241,26 @@ func (t *State) handleCSI() {
 		t.saveCursor()
 	case 'u': // DECRC - restore Cursor position (ANSI.SYS)
 		t.restoreCursor()
-	case 'q': /*
-	TODO: Handle cursor block shape
-	Ps = 0,1    Block cursor / Blink
-	   = 2      Block cursor / Steady
-	   = 3      Underline cursor / Blink
-	   = 4      Underline cursor / Steady
-	   = 5      Vertical line cursor / Blink
-	   = 6      Vertical line cursor / Steady
-	*/
+	case 'q': // DECSCUSR - set cursor style
+		if c.inter != " " {
+			goto unknown
+		}
+		switch n := c.arg(0, 0); n {
+		case 0, 1, 2: // block
+			t.setCursorStyle(CursorBlock, n != 2)
+		case 3, 4: // underline
+			t.setCursorStyle(CursorUnderline, n == 3)
+		case 5, 6: // vertical bar
+			t.setCursorStyle(CursorBar, n == 5)
+		default:
+			goto unknown
+		}
 	case 't': // Ignoring IME state set change
 	}
 
 	return
 unknown: // TODO: get rid of this goto
-	t.logf("unknown CSI sequence '%s'\n", string(c.mode))
+	t.logf("unknown CSI sequence '%s%c'\n", c.inter, c.mode)
 
 	// TODO: Char.dump()
 }
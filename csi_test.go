@@ -1,6 +1,7 @@
 package vt10x
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -33,4 +34,49 @@ func TestCSIParse(t *testing.T) {
 	if csi.mode != 'l' || csi.arg(0, 0) != 25 || csi.priv != true || len(csi.args) != 1 {
 		t.Fatal("CSI parse mismatch")
 	}
+
+	csi.reset()
+	csi.buf = []byte(";5H")
+	csi.parse()
+	if csi.mode != 'H' || csi.arg(0, 1) != 1 || csi.arg(1, 1) != 5 || len(csi.args) != 2 {
+		t.Fatal("CSI parse mismatch")
+	}
+
+	csi.reset()
+	csi.buf = []byte("4:3;38:2::255:0:7m")
+	csi.parse()
+	if csi.mode != 'm' || csi.arg(0, 0) != 4 || csi.arg(1, 0) != 38 || len(csi.args) != 2 {
+		t.Fatal("CSI parse mismatch")
+	}
+	if fmt.Sprint(csi.sub) != "[[4 3] [38 2 0 255 0 7]]" {
+		t.Fatal("CSI sub-parameter mismatch", csi.sub)
+	}
+
+	csi.reset()
+	csi.buf = []byte("5 q")
+	csi.parse()
+	if csi.mode != 'q' || csi.inter != " " || csi.arg(0, 0) != 5 || len(csi.args) != 1 {
+		t.Fatal("CSI parse mismatch")
+	}
+}
+
+func TestCSIEmptyArgs(t *testing.T) {
+	var st State
+	term, err := Create(&st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	term.Resize(80, 24)
+	term.Write([]byte("\033[3;r"))
+	if st.top != 2 || st.bottom != 23 {
+		t.Fatalf("scroll region %d-%d, expected 2-23", st.top, st.bottom)
+	}
+	term.Write([]byte("\033[5;H"))
+	if x, y := st.Cursor(); x != 0 || y != 4 {
+		t.Fatalf("cursor at %d,%d, expected 0,4", x, y)
+	}
+	term.Write([]byte("\033[;7H"))
+	if x, y := st.Cursor(); x != 6 || y != 0 {
+		t.Fatalf("cursor at %d,%d, expected 6,0", x, y)
+	}
 }
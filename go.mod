@@ -4,7 +4,6 @@ go 1.17
 
 require (
 	github.com/creack/pty v1.1.17
+	github.com/mattn/go-runewidth v0.0.9
 	github.com/nsf/termbox-go v1.1.1
 )
-
-require github.com/mattn/go-runewidth v0.0.9 // indirect
@@ -0,0 +1,80 @@
+package vt10x
+
+import "fmt"
+
+// history is a bounded ring of lines scrolled off the top of the primary
+// screen. The oldest line is at index 0.
+type history struct {
+	lines []Line
+	start int
+	n     int
+}
+
+// push appends a copy of l, dropping the oldest line once max lines are
+// stored. A max of zero or less disables the history.
+func (h *history) push(l Line, max int) {
+	if max <= 0 {
+		h.reset()
+		return
+	}
+	if len(h.lines) != max {
+		h.grow(max)
+	}
+	i := (h.start + h.n) % max
+	if h.n == max {
+		h.start = (h.start + 1) % max
+	} else {
+		h.n++
+	}
+	// reuse the backing array of the evicted line where possible
+	h.lines[i] = append(h.lines[i][:0], l...)
+}
+
+// grow reallocates the ring for a new capacity, keeping the newest lines.
+func (h *history) grow(max int) {
+	lines := make([]Line, max)
+	n := min(h.n, max)
+	for i := 0; i < n; i++ {
+		lines[i] = h.at(h.n - n + i)
+	}
+	h.lines = lines
+	h.start = 0
+	h.n = n
+}
+
+func (h *history) at(i int) Line {
+	if i < 0 || i >= h.n {
+		panic(fmt.Sprintf("vt10x: history line %d out of range [0:%d]", i, h.n))
+	}
+	return h.lines[(h.start+i)%len(h.lines)]
+}
+
+func (h *history) len() int {
+	return h.n
+}
+
+func (h *history) reset() {
+	h.lines = nil
+	h.start = 0
+	h.n = 0
+}
+
+// HistoryLen returns the number of lines in the scrollback history.
+func (t *State) HistoryLen() int {
+	return t.history.len()
+}
+
+// HistoryLine returns line i of the scrollback history, where 0 is the
+// oldest line and HistoryLen()-1 the line directly above the screen. Lines
+// keep the width the terminal had when they were scrolled off. The line is
+// a copy, as the history reuses the memory of evicted lines.
+func (t *State) HistoryLine(i int) Line {
+	return append(Line(nil), t.history.at(i)...)
+}
+
+// pushHistory saves lines scrolled off the top of the primary screen.
+func (t *State) pushHistory(lines ...Line) {
+	for _, l := range lines {
+		t.history.push(l, t.MaxHistory)
+	}
+}
@@ -0,0 +1,53 @@
+package vt10x
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	st := State{MaxHistory: 3}
+	term, err := Create(&st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	term.Resize(10, 2)
+	for i := 0; i < 6; i++ {
+		fmt.Fprintf(term, "line %d\r\n", i)
+	}
+	// rows 0 and 1 hold "line 5" and the empty prompt line
+	if st.HistoryLen() != 3 {
+		t.Fatalf("history length %d, expected 3", st.HistoryLen())
+	}
+	for i := 0; i < 3; i++ {
+		expected := fmt.Sprintf("line %d", i+2)
+		if actual := strings.TrimRight(st.HistoryLine(i).String(), " "); actual != expected {
+			t.Fatalf("history line %d: %q, expected %q", i, actual, expected)
+		}
+	}
+
+	// returned lines are not overwritten when evicted
+	held := st.HistoryLine(0)
+	fmt.Fprintf(term, "line 6\r\n")
+	if actual := strings.TrimRight(held.String(), " "); actual != "line 2" {
+		t.Fatalf("held history line changed to %q", actual)
+	}
+
+	// the alt screen does not feed the history
+	term.Write([]byte("\033[?1049h\r\n\r\n\r\n\033[?1049l"))
+	if st.HistoryLen() != 3 {
+		t.Fatalf("history length %d after alt screen, expected 3", st.HistoryLen())
+	}
+
+	term.Write([]byte("\033c"))
+	if st.HistoryLen() != 0 {
+		t.Fatalf("history length %d after reset, expected 0", st.HistoryLen())
+	}
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(fmt.Sprint(r), "out of range") {
+			t.Fatalf("expected an out of range panic, got %v", r)
+		}
+	}()
+	st.HistoryLine(0)
+}
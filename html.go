@@ -0,0 +1,165 @@
+package vt10x
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// HTMLOptions control the output of WriteHTML.
+type HTMLOptions struct {
+	// Theme maps colors to RGB. The current colors of the terminal are
+	// used if it is nil.
+	Theme *Theme
+	// Scrollback includes the history above the primary screen. It is
+	// ignored while the alt screen is shown.
+	Scrollback bool
+	// FontFamily is the CSS font family of the text, monospace if empty.
+	FontFamily string
+}
+
+// WriteHTML writes the screen as a standalone HTML document. Runs of cells
+// with the same colors and attributes share a span, and the cursor, when
+// visible, is a span of class "cursor".
+func (t *State) WriteHTML(w io.Writer, opts HTMLOptions) error {
+	th := t.Theme()
+	if opts.Theme != nil {
+		th = *opts.Theme
+	}
+	if t.mode&ModeReverse != 0 {
+		th.Foreground, th.Background = th.Background, th.Foreground
+	}
+	font := opts.FontFamily
+	if font == "" {
+		font = "monospace"
+	}
+
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n</head>\n",
+		html.EscapeString(t.title))
+	fmt.Fprintf(b, "<body style=\"margin:0;background:%s\">\n", cssColor(th.Background))
+	fmt.Fprintf(b, "<pre style=\"margin:0;padding:0.5em;font-family:%s;color:%s;background:%s\">",
+		html.EscapeString(font), cssColor(th.Foreground), cssColor(th.Background))
+	if opts.Scrollback && t.mode&ModeAltScreen == 0 {
+		for i := 0; i < t.HistoryLen(); i++ {
+			writeHTMLLine(b, t.history.at(i), -1, &th)
+			b.WriteByte('\n')
+		}
+	}
+	for y := 0; y < t.rows; y++ {
+		cx := -1
+		if y == t.Cur.y && t.CursorVisible() {
+			cx = t.Cur.x
+		}
+		writeHTMLLine(b, t.lines[y], cx, &th)
+		if y < t.rows-1 {
+			b.WriteByte('\n')
+		}
+	}
+	b.WriteString("</pre>\n</body>\n</html>\n")
+	return b.Flush()
+}
+
+// writeHTMLLine writes the cells of l up to the trailing blanks, with the
+// cursor at column cx if it is not negative.
+func writeHTMLLine(b *bufio.Writer, l Line, cx int, th *Theme) {
+	n := len(l)
+	for n > 0 && n-1 > cx && l[n-1] == blankGlyph {
+		n--
+	}
+	var run strings.Builder
+	style := ""
+	flush := func() {
+		if run.Len() == 0 {
+			return
+		}
+		if style == "" {
+			b.WriteString(run.String())
+		} else {
+			fmt.Fprintf(b, "<span style=\"%s\">%s</span>", style, run.String())
+		}
+		run.Reset()
+	}
+	for x := 0; x < n; x++ {
+		g := l[x]
+		if g.Mode&attrWDummy != 0 {
+			continue
+		}
+		text := html.EscapeString(g.String())
+		if x == cx {
+			flush()
+			// the cursor is drawn as a block in the cursor color
+			fmt.Fprintf(b, "<span class=\"cursor\" style=\"color:%s;background:%s\">%s</span>",
+				cssColor(th.Resolve(g.Bg)), cssColor(th.Cursor), text)
+			continue
+		}
+		if s := htmlStyle(g, th); s != style {
+			flush()
+			style = s
+		}
+		run.WriteString(text)
+	}
+	flush()
+}
+
+// htmlStyle returns the inline CSS of the colors and attributes of g, or
+// an empty string for a plain cell.
+func htmlStyle(g Glyph, th *Theme) string {
+	var s []string
+	attrs := g.Attrs()
+	fg := g.Fg
+	if attrs&AttrInvisible != 0 {
+		fg = g.Bg
+	}
+	if fg != DefaultFG {
+		s = append(s, "color:"+cssColor(th.Resolve(fg)))
+	}
+	if g.Bg != DefaultBG {
+		s = append(s, "background:"+cssColor(th.Resolve(g.Bg)))
+	}
+	if attrs&AttrBold != 0 {
+		s = append(s, "font-weight:bold")
+	}
+	if attrs&AttrFaint != 0 {
+		s = append(s, "opacity:0.5")
+	}
+	if attrs&AttrItalic != 0 {
+		s = append(s, "font-style:italic")
+	}
+
+	var lines []string
+	if attrs&attrUnderlines != 0 {
+		lines = append(lines, "underline")
+	}
+	if attrs&AttrStrike != 0 {
+		lines = append(lines, "line-through")
+	}
+	if attrs&AttrOverline != 0 {
+		lines = append(lines, "overline")
+	}
+	if lines != nil {
+		s = append(s, "text-decoration:"+strings.Join(lines, " "))
+		switch {
+		case attrs&AttrDoubleUnderline != 0:
+			s = append(s, "text-decoration-style:double")
+		case attrs&AttrCurlyUnderline != 0:
+			s = append(s, "text-decoration-style:wavy")
+		case attrs&AttrDottedUnderline != 0:
+			s = append(s, "text-decoration-style:dotted")
+		case attrs&AttrDashedUnderline != 0:
+			s = append(s, "text-decoration-style:dashed")
+		}
+		if attrs&attrUnderlines != 0 && g.Ul != DefaultUL {
+			s = append(s, "text-decoration-color:"+cssColor(th.Resolve(g.Ul)))
+		}
+	}
+	return strings.Join(s, ";")
+}
+
+// cssColor returns the #rrggbb notation of an RGB Color.
+func cssColor(c Color) string {
+	r, g, b := c.RGB()
+	return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+}
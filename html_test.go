@@ -0,0 +1,42 @@
+package vt10x
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteHTML(t *testing.T) {
+	var st State
+	term, err := Create(&st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	term.Resize(20, 3)
+	term.Write([]byte("\033]0;a <title>\a\033[1;31mab\033[0m c\033[4:3;58;5;4m<d>\033[0m\r\n\033[7me"))
+
+	var b strings.Builder
+	if err := st.WriteHTML(&b, HTMLOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, expected := range []string{
+		"<title>a &lt;title&gt;</title>",
+		`<span style="color:#ff0000;font-weight:bold">ab</span> c`,
+		`<span style="text-decoration:underline;text-decoration-style:wavy;text-decoration-color:#0000ee">&lt;d&gt;</span>`,
+		`<span style="color:#000000;background:#e5e5e5">e</span><span class="cursor" style="color:#000000;background:#e5e5e5"> </span>`,
+		"</pre>",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("missing %q in\n%s", expected, out)
+		}
+	}
+
+	// a theme replaces the palette
+	theme := DefaultTheme
+	theme.Palette[Red+8] = RGB(1, 2, 3)
+	b.Reset()
+	st.WriteHTML(&b, HTMLOptions{Theme: &theme})
+	if !strings.Contains(b.String(), `<span style="color:#010203;font-weight:bold">ab</span>`) {
+		t.Fatalf("theme not applied:\n%s", b.String())
+	}
+}
@@ -0,0 +1,84 @@
+package vt10x
+
+import (
+	"strings"
+)
+
+// Modifier is a set of modifier keys held during an input event.
+type Modifier uint8
+
+// Modifier keys
+const (
+	ModShift Modifier = 1 << iota
+	ModAlt
+	ModCtrl
+	ModMeta
+)
+
+// sendInput encodes an input event while holding the state lock and
+// writes the result to the application. Nothing is written if the event
+// encodes to no bytes or if there is no output.
+func (t *VT) sendInput(encode func() []byte) error {
+	t.dest.lock()
+	p := encode()
+	w := t.dest.out
+	t.dest.unlock()
+	if len(p) == 0 || w == nil {
+		return nil
+	}
+	t.recordInput(p)
+	_, err := w.Write(p)
+	return err
+}
+
+// Paste writes pasted text to the application, wrapped in ESC [200~ and
+// ESC [201~ when bracketed paste mode is enabled. Line endings are sent as
+// CR, and control characters other than tab are removed so that the text
+// cannot end the paste early or inject escape sequences.
+func (t *VT) Paste(text string) error {
+	return t.sendInput(func() []byte {
+		s := sanitizePaste(text)
+		if t.dest.mode&ModeBracketedPaste != 0 {
+			s = "\033[200~" + s + "\033[201~"
+		}
+		return []byte(s)
+	})
+}
+
+func sanitizePaste(text string) string {
+	var b strings.Builder
+	text = strings.ReplaceAll(text, "\r\n", "\r")
+	for _, r := range text {
+		switch {
+		case r == '\n':
+			b.WriteRune('\r')
+		case r == '\t', r == '\r':
+			b.WriteRune(r)
+		case r < 0x20, r == 0x7f, r >= 0x80 && r < 0xa0:
+			// C0 and C1 controls, including ESC and CSI
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// SetFocused records whether the terminal window has focus and, when the
+// application enabled focus reporting, tells it with CSI I or CSI O. It is
+// safe to call while Parse is running.
+func (t *VT) SetFocused(focused bool) error {
+	return t.sendInput(func() []byte {
+		if t.dest.unfocused != focused {
+			return nil
+		}
+		t.dest.unfocused = !focused
+		t.dest.changed |= ChangedFocus
+		if t.dest.mode&ModeFocus == 0 {
+			return nil
+		}
+		if focused {
+			return []byte("\033[I")
+		}
+		return []byte("\033[O")
+	})
+}
@@ -0,0 +1,68 @@
+package vt10x
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPaste(t *testing.T) {
+	var st State
+	term, err := Create(&st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	term.SetOutput(&out)
+
+	tests := []struct {
+		setup, text, expected string
+	}{
+		{"", "ls\n", "ls\r"},
+		{"\033[?2004h", "a\r\nb\tc", "\033[200~a\rb\tc\033[201~"},
+		{"", "x\033[201~; rm -rf ~\n", "\033[200~x[201~; rm -rf ~\r\033[201~"},
+		{"", "\u009b201~\aé", "\033[200~201~é\033[201~"},
+		{"\033[?2004l", "echo \033[31m", "echo [31m"},
+	}
+	for _, test := range tests {
+		term.Write([]byte(test.setup))
+		out.Reset()
+		if err := term.Paste(test.text); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != test.expected {
+			t.Fatalf("%q: got %q, expected %q", test.text, out.String(), test.expected)
+		}
+	}
+}
+
+func TestSetFocused(t *testing.T) {
+	var st State
+	term, err := Create(&st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	term.SetOutput(&out)
+
+	tests := []struct {
+		setup    string
+		focused  bool
+		expected string
+	}{
+		{"", false, ""},
+		{"\033[?1004h", true, "\033[I"},
+		{"", true, ""},
+		{"", false, "\033[O"},
+		{"\033[?1004l", true, ""},
+	}
+	for i, test := range tests {
+		term.Write([]byte(test.setup))
+		out.Reset()
+		if err := term.SetFocused(test.focused); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != test.expected || st.Focused() != test.focused {
+			t.Fatalf("%d: got %q focused %v", i, out.String(), st.Focused())
+		}
+	}
+}
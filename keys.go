@@ -0,0 +1,254 @@
+package vt10x
+
+import (
+	"fmt"
+)
+
+// Key is a key of a KeyEvent.
+type Key uint8
+
+// Keys
+const (
+	KeyRune Key = iota // text given by KeyEvent.Runes
+	KeyEnter
+	KeyTab
+	KeyBackspace
+	KeyEscape
+	KeyUp
+	KeyDown
+	KeyRight
+	KeyLeft
+	KeyHome
+	KeyEnd
+	KeyInsert
+	KeyDelete
+	KeyPageUp
+	KeyPageDown
+	KeyF1
+	KeyF2
+	KeyF3
+	KeyF4
+	KeyF5
+	KeyF6
+	KeyF7
+	KeyF8
+	KeyF9
+	KeyF10
+	KeyF11
+	KeyF12
+	KeyF13
+	KeyF14
+	KeyF15
+	KeyF16
+	KeyF17
+	KeyF18
+	KeyF19
+	KeyF20
+	KeyF21
+	KeyF22
+	KeyF23
+	KeyF24
+	KeyKP0
+	KeyKP1
+	KeyKP2
+	KeyKP3
+	KeyKP4
+	KeyKP5
+	KeyKP6
+	KeyKP7
+	KeyKP8
+	KeyKP9
+	KeyKPEnter
+	KeyKPPlus
+	KeyKPMinus
+	KeyKPMultiply
+	KeyKPDivide
+	KeyKPDecimal
+	KeyKPEqual
+)
+
+// KeyEvent is a key press. Runes holds the text typed for KeyRune, already
+// shifted; Shift only affects the encoding of other keys.
+type KeyEvent struct {
+	Key   Key
+	Runes []rune
+	Mod   Modifier
+}
+
+// SendKey writes the xterm byte sequence of a key press to the
+// application, honoring the cursor key (DECCKM) and keypad (DECKPAM)
+// modes. Alt and Meta prefix text with ESC.
+func (t *VT) SendKey(ev KeyEvent) error {
+	return t.sendInput(func() []byte {
+		return t.dest.encodeKey(ev)
+	})
+}
+
+// final bytes of the cursor keys, sent as CSI <c> or SS3 <c>
+var cursorKeys = map[Key]byte{
+	KeyUp:    'A',
+	KeyDown:  'B',
+	KeyRight: 'C',
+	KeyLeft:  'D',
+	KeyHome:  'H',
+	KeyEnd:   'F',
+}
+
+// parameters of the keys sent as CSI <n> ~
+var tildeKeys = map[Key]int{
+	KeyInsert:   2,
+	KeyDelete:   3,
+	KeyPageUp:   5,
+	KeyPageDown: 6,
+	KeyF5:       15,
+	KeyF6:       17,
+	KeyF7:       18,
+	KeyF8:       19,
+	KeyF9:       20,
+	KeyF10:      21,
+	KeyF11:      23,
+	KeyF12:      24,
+}
+
+// final bytes of the keypad in application mode, sent as SS3 <c>
+var keypadKeys = map[Key]byte{
+	KeyKP0:        'p',
+	KeyKP1:        'q',
+	KeyKP2:        'r',
+	KeyKP3:        's',
+	KeyKP4:        't',
+	KeyKP5:        'u',
+	KeyKP6:        'v',
+	KeyKP7:        'w',
+	KeyKP8:        'x',
+	KeyKP9:        'y',
+	KeyKPEnter:    'M',
+	KeyKPPlus:     'k',
+	KeyKPMinus:    'm',
+	KeyKPMultiply: 'j',
+	KeyKPDivide:   'o',
+	KeyKPDecimal:  'n',
+	KeyKPEqual:    'X',
+}
+
+// text of the keypad in numeric mode
+var keypadText = map[Key]string{
+	KeyKPPlus:     "+",
+	KeyKPMinus:    "-",
+	KeyKPMultiply: "*",
+	KeyKPDivide:   "/",
+	KeyKPDecimal:  ".",
+	KeyKPEqual:    "=",
+}
+
+// encodeKey returns the xterm encoding of ev for the current modes.
+func (t *State) encodeKey(ev KeyEvent) []byte {
+	if t.mode&ModeKeyboardLock != 0 {
+		return nil
+	}
+	key, mod := ev.Key, ev.Mod
+	// F13-F24 are the shifted F1-F12
+	if key >= KeyF13 && key <= KeyF24 {
+		key -= KeyF13 - KeyF1
+		mod |= ModShift
+	}
+	// xterm modifier parameter
+	param := 1
+	if mod&ModShift != 0 {
+		param += 1
+	}
+	if mod&ModAlt != 0 {
+		param += 2
+	}
+	if mod&ModCtrl != 0 {
+		param += 4
+	}
+	if mod&ModMeta != 0 {
+		param += 8
+	}
+	esc := ""
+	if mod&(ModAlt|ModMeta) != 0 {
+		esc = "\033"
+	}
+
+	if c, ok := cursorKeys[key]; ok {
+		switch {
+		case param > 1:
+			return []byte(fmt.Sprintf("\033[1;%d%c", param, c))
+		case t.mode&ModeAppCursor != 0:
+			return []byte{033, 'O', c}
+		}
+		return []byte{033, '[', c}
+	}
+	if n, ok := tildeKeys[key]; ok {
+		if param > 1 {
+			return []byte(fmt.Sprintf("\033[%d;%d~", n, param))
+		}
+		return []byte(fmt.Sprintf("\033[%d~", n))
+	}
+	if key >= KeyF1 && key <= KeyF4 {
+		c := byte('P' + key - KeyF1)
+		if param > 1 {
+			return []byte(fmt.Sprintf("\033[1;%d%c", param, c))
+		}
+		return []byte{033, 'O', c}
+	}
+	if c, ok := keypadKeys[key]; ok && t.mode&ModeAppKeypad != 0 {
+		return []byte(esc + "\033O" + string(c))
+	}
+
+	switch key {
+	case KeyEnter, KeyKPEnter:
+		if t.mode&ModeCRLF != 0 {
+			return []byte(esc + "\r\n")
+		}
+		return []byte(esc + "\r")
+	case KeyTab:
+		if mod&ModShift != 0 {
+			return []byte("\033[Z")
+		}
+		return []byte(esc + "\t")
+	case KeyBackspace:
+		if mod&ModCtrl != 0 {
+			return []byte(esc + "\b")
+		}
+		return []byte(esc + "\177")
+	case KeyEscape:
+		return []byte(esc + "\033")
+	case KeyRune:
+		s := esc
+		for _, r := range ev.Runes {
+			if mod&ModCtrl != 0 {
+				r = ctrlRune(r)
+			}
+			s += string(r)
+		}
+		return []byte(s)
+	}
+	if key >= KeyKP0 && key <= KeyKP9 {
+		return []byte(esc + string(rune('0'+key-KeyKP0)))
+	}
+	if s, ok := keypadText[key]; ok {
+		return []byte(esc + s)
+	}
+	return nil
+}
+
+// ctrlRune returns the control character typed for r with Ctrl held.
+func ctrlRune(r rune) rune {
+	switch {
+	case r >= 'a' && r <= 'z':
+		return r - 'a' + 1
+	case r >= '@' && r <= '_':
+		return r - '@'
+	case r == ' ' || r == '2':
+		return 0
+	case r >= '3' && r <= '7':
+		return r - '3' + 033
+	case r == '8' || r == '?':
+		return 0177
+	case r == '/':
+		return 037
+	}
+	return r
+}
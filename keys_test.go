@@ -0,0 +1,61 @@
+package vt10x
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSendKey(t *testing.T) {
+	var st State
+	term, err := Create(&st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	term.SetOutput(&out)
+
+	tests := []struct {
+		setup    string
+		ev       KeyEvent
+		expected string
+	}{
+		{"", KeyEvent{Key: KeyUp}, "\033[A"},
+		{"\033[?1h", KeyEvent{Key: KeyUp}, "\033OA"},
+		{"\033[?1h", KeyEvent{Key: KeyLeft, Mod: ModCtrl}, "\033[1;5D"},
+		{"\033[?1l", KeyEvent{Key: KeyHome, Mod: ModShift | ModAlt}, "\033[1;4H"},
+		{"", KeyEvent{Key: KeyEnd}, "\033[F"},
+		{"", KeyEvent{Key: KeyDelete}, "\033[3~"},
+		{"", KeyEvent{Key: KeyPageUp, Mod: ModMeta}, "\033[5;9~"},
+		{"", KeyEvent{Key: KeyF1}, "\033OP"},
+		{"", KeyEvent{Key: KeyF4, Mod: ModCtrl}, "\033[1;5S"},
+		{"", KeyEvent{Key: KeyF5}, "\033[15~"},
+		{"", KeyEvent{Key: KeyF12, Mod: ModShift}, "\033[24;2~"},
+		{"", KeyEvent{Key: KeyF13}, "\033[1;2P"},
+		{"", KeyEvent{Key: KeyF24}, "\033[24;2~"},
+		{"", KeyEvent{Key: KeyKP5}, "5"},
+		{"\033=", KeyEvent{Key: KeyKP5}, "\033Ou"},
+		{"\033=", KeyEvent{Key: KeyKPEnter}, "\033OM"},
+		{"\033>", KeyEvent{Key: KeyKPEnter}, "\r"},
+		{"\033[20h", KeyEvent{Key: KeyEnter}, "\r\n"},
+		{"\033[20l", KeyEvent{Key: KeyEnter, Mod: ModAlt}, "\033\r"},
+		{"", KeyEvent{Key: KeyTab, Mod: ModShift}, "\033[Z"},
+		{"", KeyEvent{Key: KeyBackspace}, "\177"},
+		{"", KeyEvent{Key: KeyBackspace, Mod: ModCtrl}, "\b"},
+		{"", KeyEvent{Key: KeyRune, Runes: []rune("hé")}, "hé"},
+		{"", KeyEvent{Key: KeyRune, Runes: []rune("c"), Mod: ModCtrl}, "\003"},
+		{"", KeyEvent{Key: KeyRune, Runes: []rune("x"), Mod: ModAlt}, "\033x"},
+		{"", KeyEvent{Key: KeyRune, Runes: []rune(" "), Mod: ModCtrl | ModAlt}, "\033\000"},
+		// KAM locks the keyboard
+		{"\033[2h", KeyEvent{Key: KeyRune, Runes: []rune("x")}, ""},
+	}
+	for _, test := range tests {
+		term.Write([]byte(test.setup))
+		out.Reset()
+		if err := term.SendKey(test.ev); err != nil {
+			t.Fatal(err)
+		}
+		if out.String() != test.expected {
+			t.Fatalf("%+v: got %q, expected %q", test.ev, out.String(), test.expected)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package vt10x
+
+import (
+	"fmt"
+)
+
+// MouseButton is the button of a MouseEvent.
+type MouseButton uint8
+
+// Mouse buttons
+const (
+	MouseNone MouseButton = iota // motion without a pressed button
+	MouseLeft
+	MouseMiddle
+	MouseRight
+	MouseWheelUp
+	MouseWheelDown
+	MouseWheelLeft
+	MouseWheelRight
+)
+
+// MouseAction is the kind of a MouseEvent.
+type MouseAction uint8
+
+// Mouse actions
+const (
+	MousePress MouseAction = iota
+	MouseRelease
+	MouseMotion // Button is the button held while moving
+)
+
+// MouseEvent is a mouse event over the cell at (X, Y), relative to the top
+// left of the terminal.
+type MouseEvent struct {
+	X, Y   int
+	Button MouseButton
+	Action MouseAction
+	Mod    Modifier
+}
+
+// SendMouse reports a mouse event to the application, if the enabled mouse
+// reporting mode includes it, using the encoding the application selected.
+func (t *VT) SendMouse(ev MouseEvent) error {
+	return t.sendInput(func() []byte {
+		return t.dest.encodeMouse(ev)
+	})
+}
+
+// encodeMouse returns the xterm encoding of ev for the current mouse
+// modes, or nil if ev is not reported.
+func (t *State) encodeMouse(ev MouseEvent) []byte {
+	if t.mode&ModeMouseMask == 0 {
+		return nil
+	}
+	wheel := ev.Button >= MouseWheelUp
+	switch ev.Action {
+	case MousePress:
+		if ev.Button == MouseNone {
+			return nil
+		}
+	case MouseRelease:
+		// X10 reports presses only, and wheels have no release
+		if t.mode&ModeMouseX10 != 0 || wheel {
+			return nil
+		}
+	case MouseMotion:
+		drag := t.mode&ModeMouseMotion != 0 && ev.Button != MouseNone
+		if !drag && t.mode&ModeMouseMany == 0 {
+			return nil
+		}
+	}
+
+	var code int
+	switch {
+	case ev.Button == MouseNone:
+		code = 3
+	case wheel:
+		code = 64 + int(ev.Button-MouseWheelUp)
+	default:
+		code = int(ev.Button - MouseLeft)
+	}
+	sgr := t.mode&ModeMouseSgr != 0
+	if ev.Action == MouseRelease && !sgr {
+		// only SGR tells which button was released
+		code = 3
+	}
+	if ev.Action == MouseMotion {
+		code += 32
+	}
+	if t.mode&ModeMouseX10 == 0 {
+		if ev.Mod&ModShift != 0 {
+			code |= 4
+		}
+		if ev.Mod&(ModAlt|ModMeta) != 0 {
+			code |= 8
+		}
+		if ev.Mod&ModCtrl != 0 {
+			code |= 16
+		}
+	}
+	x := clamp(ev.X, 0, t.cols-1) + 1
+	y := clamp(ev.Y, 0, t.rows-1) + 1
+
+	switch {
+	case sgr:
+		final := 'M'
+		if ev.Action == MouseRelease {
+			final = 'm'
+		}
+		return []byte(fmt.Sprintf("\033[<%d;%d;%d%c", code, x, y, final))
+	case t.mode&ModeMouseUrxvt != 0:
+		return []byte(fmt.Sprintf("\033[%d;%d;%dM", 32+code, x, y))
+	case t.mode&ModeMouseUtf8 != 0:
+		// coordinates are limited to two byte sequences
+		if 32+x > 0x7ff || 32+y > 0x7ff {
+			return nil
+		}
+		p := []byte("\033[M")
+		for _, v := range []int{code, x, y} {
+			p = append(p, string(rune(32+v))...)
+		}
+		return p
+	}
+	if 32+x > 0xff || 32+y > 0xff {
+		return nil
+	}
+	return []byte{033, '[', 'M', byte(32 + code), byte(32 + x), byte(32 + y)}
+}
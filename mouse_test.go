@@ -0,0 +1,60 @@
+package vt10x
+
+import (
+	"testing"
+)
+
+func TestEncodeMouse(t *testing.T) {
+	var st State
+	_, err := Create(&st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	press := MouseEvent{X: 2, Y: 4, Button: MouseLeft}
+	release := MouseEvent{X: 2, Y: 4, Button: MouseLeft, Action: MouseRelease}
+	drag := MouseEvent{X: 3, Y: 4, Button: MouseLeft, Action: MouseMotion}
+	move := MouseEvent{X: 3, Y: 4, Action: MouseMotion}
+	wheel := MouseEvent{X: 0, Y: 0, Button: MouseWheelDown, Mod: ModCtrl}
+	far := MouseEvent{X: 300, Y: 0, Button: MouseRight, Mod: ModShift | ModAlt}
+
+	tests := []struct {
+		modes    ModeFlag
+		ev       MouseEvent
+		expected string
+	}{
+		{0, press, ""},
+		{ModeMouseX10, press, "\033[M #%"},
+		{ModeMouseX10, release, ""},
+		{ModeMouseX10, MouseEvent{Button: MouseMiddle, Mod: ModCtrl}, "\033[M!!!"},
+		{ModeMouseButton, release, "\033[M##%"},
+		{ModeMouseButton, drag, ""},
+		{ModeMouseButton, wheel, "\033[Mq!!"},
+		{ModeMouseMotion, drag, "\033[M@$%"},
+		{ModeMouseMotion, move, ""},
+		{ModeMouseMany, move, "\033[MC$%"},
+		{ModeMouseButton | ModeMouseSgr, press, "\033[<0;3;5M"},
+		{ModeMouseButton | ModeMouseSgr, release, "\033[<0;3;5m"},
+		{ModeMouseButton | ModeMouseSgr, wheel, "\033[<81;1;1M"},
+		{ModeMouseButton | ModeMouseSgr, far, "\033[<14;80;1M"},
+		{ModeMouseButton | ModeMouseUrxvt, press, "\033[32;3;5M"},
+		{ModeMouseButton | ModeMouseUtf8, press, "\033[M #%"},
+	}
+	for i, test := range tests {
+		st.mode = test.modes
+		if actual := string(st.encodeMouse(test.ev)); actual != test.expected {
+			t.Errorf("%d: got %q, expected %q", i, actual, test.expected)
+		}
+	}
+
+	// coordinates beyond the legacy encodings
+	st.resize(400, 10)
+	far.X = 300
+	st.mode = ModeMouseButton
+	if p := st.encodeMouse(far); p != nil {
+		t.Errorf("got %q for unencodable position", p)
+	}
+	st.mode = ModeMouseButton | ModeMouseUtf8
+	if actual := string(st.encodeMouse(far)); actual != "\033[M."+string(rune(333))+"!" {
+		t.Errorf("got %q", actual)
+	}
+}
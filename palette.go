@@ -0,0 +1,131 @@
+package vt10x
+
+// palette holds the colors applications can change with OSC sequences.
+type palette struct {
+	colors              [256]Color
+	fg, bg, cursorColor Color
+}
+
+func (p *palette) reset() {
+	p.colors = DefaultPalette
+	p.fg = DefaultPalette[LightGrey]
+	p.bg = DefaultPalette[Black]
+	p.cursorColor = DefaultFG
+}
+
+// ResolveColor returns the RGB Color currently displayed for c, looking
+// up palette indexes and default colors in the terminal palette.
+func (t *State) ResolveColor(c Color) Color {
+	switch {
+	case c.IsRGB():
+		return c
+	case c.Palette():
+		return t.palette.colors[c]
+	case c == DefaultBG:
+		return t.palette.bg
+	}
+	return t.palette.fg
+}
+
+// CursorColor returns the RGB color of the cursor.
+func (t *State) CursorColor() Color {
+	return t.ResolveColor(t.palette.cursorColor)
+}
+
+// oscColor sets or, for a "?" spec, reports the color of OSC command d,
+// where d is 4 for palette entry i and 10, 11 or 12 for the default
+// foreground, background and cursor colors.
+func (t *State) oscColor(d, i int, spec string) {
+	var c *Color
+	switch d {
+	case 4:
+		if !between(i, 0, 255) {
+			t.logf("bad color index %d\n", i)
+			return
+		}
+		c = &t.palette.colors[i]
+	case 10:
+		c = &t.palette.fg
+	case 11:
+		c = &t.palette.bg
+	case 12:
+		c = &t.palette.cursorColor
+	}
+	if spec == "?" {
+		r, g, b := t.ResolveColor(*c).RGB()
+		if d == 4 {
+			t.oscReply("4;%d;rgb:%04x/%04x/%04x", i,
+				int(r)*0x101, int(g)*0x101, int(b)*0x101)
+		} else {
+			t.oscReply("%d;rgb:%04x/%04x/%04x", d,
+				int(r)*0x101, int(g)*0x101, int(b)*0x101)
+		}
+		return
+	}
+	v, ok := parseColorSpec(spec)
+	if !ok {
+		t.logf("invalid color spec '%s'\n", spec)
+		return
+	}
+	*c = v
+	t.changed |= ChangedPalette
+}
+
+// oscResetColor restores the default color of OSC command d, as oscColor.
+func (t *State) oscResetColor(d, i int) {
+	switch d {
+	case 4:
+		if !between(i, 0, 255) {
+			t.logf("bad color index %d\n", i)
+			return
+		}
+		t.palette.colors[i] = DefaultPalette[i]
+	case 10:
+		t.palette.fg = DefaultPalette[LightGrey]
+	case 11:
+		t.palette.bg = DefaultPalette[Black]
+	case 12:
+		t.palette.cursorColor = DefaultFG
+	}
+	t.changed |= ChangedPalette
+}
+
+// Theme is the set of RGB colors an exporter draws the terminal with.
+type Theme struct {
+	Palette    [256]Color // the indexed colors
+	Foreground Color      // DefaultFG and DefaultUL
+	Background Color      // DefaultBG
+	Cursor     Color
+}
+
+// DefaultTheme holds the xterm colors.
+var DefaultTheme = Theme{
+	Palette:    DefaultPalette,
+	Foreground: DefaultPalette[LightGrey],
+	Background: DefaultPalette[Black],
+	Cursor:     DefaultPalette[LightGrey],
+}
+
+// Theme returns the colors the terminal currently uses, including those
+// changed by the application with OSC sequences.
+func (t *State) Theme() Theme {
+	return Theme{
+		Palette:    t.palette.colors,
+		Foreground: t.palette.fg,
+		Background: t.palette.bg,
+		Cursor:     t.CursorColor(),
+	}
+}
+
+// Resolve returns the RGB Color of c in the theme.
+func (th *Theme) Resolve(c Color) Color {
+	switch {
+	case c.IsRGB():
+		return c
+	case c.Palette():
+		return th.Palette[c]
+	case c == DefaultBG:
+		return th.Background
+	}
+	return th.Foreground
+}
@@ -0,0 +1,60 @@
+package vt10x
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPalette(t *testing.T) {
+	var st State
+	term, err := Create(&st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	term.SetOutput(&out)
+
+	if st.ResolveColor(Red) != RGB(0xcd, 0, 0) || st.ResolveColor(DefaultBG) != RGB(0, 0, 0) {
+		t.Fatal("unexpected default palette")
+	}
+
+	// clear the changes made by the initial reset
+	st.Lock()
+	st.Unlock()
+	term.Write([]byte("\033]4;1;rgb:ff/80/0;2;#123\a"))
+	if !st.Changed(ChangedPalette) {
+		t.Fatal("expected palette change")
+	}
+	if c := st.ResolveColor(Red); c != RGB(0xff, 0x80, 0) {
+		t.Fatalf("unexpected color %x", c)
+	}
+	if c := st.ResolveColor(Green); c != RGB(0x10, 0x20, 0x30) {
+		t.Fatalf("unexpected color %x", c)
+	}
+
+	term.Write([]byte("\033]11;rgb:ffff/ffff/ffff\033\\\033]10;#000000;#eeeeee\a\033]12;rgb:f/0/0\a"))
+	if st.ResolveColor(DefaultFG) != RGB(0, 0, 0) || st.ResolveColor(DefaultBG) != RGB(0xee, 0xee, 0xee) {
+		t.Fatal("unexpected default colors")
+	}
+	if st.CursorColor() != RGB(0xff, 0, 0) {
+		t.Fatal("unexpected cursor color")
+	}
+
+	tests := []struct {
+		in, expected string
+	}{
+		{"\033]4;1;?\a", "\033]4;1;rgb:ffff/8080/0000\a"},
+		{"\033]11;?\033\\", "\033]11;rgb:eeee/eeee/eeee\033\\"},
+		{"\033]104;1\033\\\033]4;1;?\a", "\033]4;1;rgb:cdcd/0000/0000\a"},
+		{"\033]104\a\033]4;2;?\a", "\033]4;2;rgb:0000/cdcd/0000\a"},
+		{"\033]111\a\033]11;?\a", "\033]11;rgb:0000/0000/0000\a"},
+		{"\033]112\a\033]12;?\a", "\033]12;rgb:0000/0000/0000\a"},
+	}
+	for _, test := range tests {
+		out.Reset()
+		term.Write([]byte(test.in))
+		if out.String() != test.expected {
+			t.Fatalf("%q: got %q, expected %q", test.in, out.String(), test.expected)
+		}
+	}
+}
@@ -1,40 +1,138 @@
 package vt10x
 
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
 func isControlCode(c rune) bool {
 	return c < 0x20 || c == 0177
 }
 
+// widths measures characters without the East Asian ambiguous widths that
+// go-runewidth picks from the locale, so the layout does not depend on the
+// host.
+var widths = &runewidth.Condition{EastAsianWidth: false}
+
+// runeWidth returns the number of cells c occupies. Variation selectors are
+// zero width, which go-runewidth does not account for.
+func runeWidth(c rune) int {
+	switch {
+	case isControlCode(c):
+		return 1
+	case between(int(c), 0xfe00, 0xfe0f), between(int(c), 0xe0100, 0xe01ef):
+		return 0
+	}
+	return widths.RuneWidth(c)
+}
+
 func (t *State) parse(c rune) {
 	if isControlCode(c) {
 		if t.handleControlCodes(c) || t.Cur.Attr.Mode&attrGfx == 0 {
 			return
 		}
 	}
-	// TODO: update selection; see st.Char:2450
+	width := runeWidth(c)
+	if width == 0 || t.joining() {
+		if x, y, ok := t.lastCell(); ok {
+			t.combine(x, y, c)
+			return
+		}
+		if width == 0 {
+			t.logf("dropped zero width character %U\n", c)
+			return
+		}
+	}
+	// a wide character does not fit on a single column terminal at all
+	if width != 2 || t.cols < 2 {
+		width = 1
+	}
 
 	if t.mode&ModeWrap != 0 && t.Cur.state&cursorWrapNext != 0 {
-		t.lines[t.Cur.y][t.Cur.x].Mode |= attrWrap
+		t.lines[t.Cur.y][t.cols-1].Mode |= attrWrap
 		t.newline(true)
 	}
 
-	if t.mode&ModeInsert != 0 && t.Cur.x+1 < t.cols {
-		// TODO: move shiz, look at st.Char:2458
-		t.logln("insert Mode not implemented")
+	// a wide character does not fit in the last column
+	if t.Cur.x+width > t.cols {
+		if t.mode&ModeWrap != 0 {
+			// the skipped column is not part of the wrapped text
+			t.clear(t.cols-1, t.Cur.y, t.cols-1, t.Cur.y)
+			t.lines[t.Cur.y][t.cols-1].Mode |= attrWrap
+			t.newline(true)
+		} else {
+			t.moveTo(t.cols-width, t.Cur.y)
+		}
+	}
+
+	// IRM shifts the rest of the line right, dropping what passes the margin
+	if t.mode&ModeInsert != 0 {
+		t.insertBlanks(width)
 	}
 
+	if t.IsSelected(t.Cur.x, t.Cur.y) || t.IsSelected(t.Cur.x+width-1, t.Cur.y) {
+		t.ClearSelection()
+	}
 	t.setChar(c, &t.Cur.Attr, t.Cur.x, t.Cur.y)
-	if t.Cur.x+1 < t.cols {
-		t.moveTo(t.Cur.x+1, t.Cur.y)
+	if width == 2 {
+		t.lines[t.Cur.y][t.Cur.x].Mode |= attrWide
+		t.setChar(0, &t.Cur.Attr, t.Cur.x+1, t.Cur.y)
+		t.lines[t.Cur.y][t.Cur.x+1].Mode |= attrWDummy
+	}
+	if t.Cur.x+width < t.cols {
+		t.moveTo(t.Cur.x+width, t.Cur.y)
 	} else {
 		t.Cur.state |= cursorWrapNext
 	}
 }
 
+// lastCell returns the position of the cell most recently written before
+// the cursor. ok is false if there is none on the current logical line.
+func (t *State) lastCell() (x, y int, ok bool) {
+	x, y = t.Cur.x, t.Cur.y
+	if t.Cur.state&cursorWrapNext == 0 {
+		if x == 0 {
+			if y == 0 || t.lines[y-1][t.cols-1].Mode&attrWrap == 0 {
+				return 0, 0, false
+			}
+			x, y = t.cols, y-1
+		}
+		x--
+	}
+	if t.lines[y][x].Mode&attrWDummy != 0 && x > 0 {
+		x--
+	}
+	return x, y, true
+}
+
+// joining reports whether the last written cell ends with a zero width
+// joiner, so that the next character belongs to the same cluster.
+func (t *State) joining() bool {
+	x, y, ok := t.lastCell()
+	return ok && strings.HasSuffix(t.lines[y][x].Comb, "\u200d")
+}
+
+// combine attaches c to the grapheme cluster of the cell at (x, y).
+func (t *State) combine(x, y int, c rune) {
+	g := &t.lines[y][x]
+	if utf8.RuneCountInString(g.Comb) >= maxCombining {
+		return
+	}
+	if t.IsSelected(x, y) {
+		t.ClearSelection()
+	}
+	g.Comb += string(c)
+	t.changed |= NewChar
+	t.Dirty[y] = true
+}
+
 func (t *State) parseEsc(c rune) {
 	if t.handleControlCodes(c) {
 		return
 	}
-	next := t.parse
+	var next parseState // nil ends the sequence
 	switch c {
 	case '[':
 		next = t.parseEscCSI
@@ -70,7 +168,7 @@ func (t *State) parseEsc(c rune) {
 			t.moveTo(t.Cur.x, t.Cur.y-1)
 		}
 	case 'Z': // DECID - identify terminal
-		// TODO: write to our writer our id
+		t.reply(primaryDA)
 	case 'c': // RIS - reset to initial state
 		t.reset()
 	case '=': // DECPAM - application keypad
@@ -83,7 +181,11 @@ func (t *State) parseEsc(c rune) {
 		t.restoreCursor()
 	case '\\': // ST - stop
 	default:
-		t.logf("unknown ESC sequence '%Char'\n", c)
+		t.logf("unknown ESC sequence '%c'\n", c)
+	}
+	if next == nil {
+		next = t.parse
+		t.esc = false
 	}
 	t.state = next
 }
@@ -94,6 +196,7 @@ func (t *State) parseEscCSI(c rune) {
 	}
 	if t.csi.put(byte(c)) {
 		t.state = t.parse
+		t.esc = false
 		t.handleCSI()
 	}
 }
@@ -104,6 +207,8 @@ func (t *State) parseEscStr(c rune) {
 		t.state = t.parseEscStrEnd
 	case '\a': // backwards compatiblity to xterm
 		t.state = t.parse
+		t.esc = false
+		t.str.bel = true
 		t.handleSTR()
 	default:
 		t.str.put(c)
@@ -115,6 +220,7 @@ func (t *State) parseEscStrEnd(c rune) {
 		return
 	}
 	t.state = t.parse
+	t.esc = false
 	if c == '\\' {
 		t.handleSTR()
 	}
@@ -135,9 +241,10 @@ func (t *State) parseEscAltCharset(c rune) {
 		'C', // Finnish (ignored)
 		'K': // German (ignored)
 	default:
-		t.logf("unknown alt. charset '%Char'\n", c)
+		t.logf("unknown alt. charset '%c'\n", c)
 	}
 	t.state = t.parse
+	t.esc = false
 }
 
 func (t *State) parseEscTest(c rune) {
@@ -153,6 +260,7 @@ func (t *State) parseEscTest(c rune) {
 		}
 	}
 	t.state = t.parse
+	t.esc = false
 }
 
 func (t *State) handleControlCodes(c rune) bool {
@@ -181,6 +289,7 @@ func (t *State) handleControlCodes(c rune) bool {
 	case 033:
 		t.csi.reset()
 		t.state = t.parseEsc
+		t.esc = true
 	// SO, SI
 	case 016, 017:
 		// different charsets not supported. apps should use the correct
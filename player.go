@@ -0,0 +1,288 @@
+package vt10x
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+)
+
+// Frame is an event of a Recording: output of the application or, if
+// Cols is not zero, a resize of the terminal.
+type Frame struct {
+	Time       time.Duration // since the start of the recording
+	Data       []byte
+	Cols, Rows int
+}
+
+// Recording is a recorded session, read with ReadAsciicast or ReadTTYRec.
+type Recording struct {
+	Width, Height int
+	Frames        []Frame // in time order
+}
+
+// Duration returns the time of the last frame.
+func (r *Recording) Duration() time.Duration {
+	if len(r.Frames) == 0 {
+		return 0
+	}
+	return r.Frames[len(r.Frames)-1].Time
+}
+
+// ReadAsciicast reads a recording in the asciicast v2 format. Input and
+// marker events are ignored.
+func ReadAsciicast(r io.Reader) (*Recording, error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<24)
+	if !s.Scan() {
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("asciicast: missing header")
+	}
+	var header asciicastHeader
+	if err := json.Unmarshal(s.Bytes(), &header); err != nil {
+		return nil, fmt.Errorf("asciicast: bad header: %v", err)
+	}
+	if header.Version != 2 {
+		return nil, fmt.Errorf("asciicast: unsupported version %d", header.Version)
+	}
+	rec := &Recording{Width: header.Width, Height: header.Height}
+	for line := 2; s.Scan(); line++ {
+		if len(bytes.TrimSpace(s.Bytes())) == 0 {
+			continue
+		}
+		var ev []interface{}
+		if err := json.Unmarshal(s.Bytes(), &ev); err != nil {
+			return nil, fmt.Errorf("asciicast: line %d: %v", line, err)
+		}
+		if len(ev) < 3 {
+			return nil, fmt.Errorf("asciicast: line %d: bad event", line)
+		}
+		ts, ok1 := ev[0].(float64)
+		code, ok2 := ev[1].(string)
+		data, ok3 := ev[2].(string)
+		if !ok1 || !ok2 || !ok3 {
+			return nil, fmt.Errorf("asciicast: line %d: bad event", line)
+		}
+		f := Frame{Time: time.Duration(ts * float64(time.Second))}
+		switch code {
+		case "o":
+			f.Data = []byte(data)
+		case "r":
+			if _, err := fmt.Sscanf(data, "%dx%d", &f.Cols, &f.Rows); err != nil || f.Cols < 1 || f.Rows < 1 {
+				return nil, fmt.Errorf("asciicast: line %d: bad size %q", line, data)
+			}
+		default:
+			continue
+		}
+		rec.Frames = append(rec.Frames, f)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(rec.Frames, func(i, j int) bool {
+		return rec.Frames[i].Time < rec.Frames[j].Time
+	})
+	return rec, nil
+}
+
+// ReadTTYRec reads a recording in the ttyrec format. The format does not
+// store the size of the terminal, which is set to 80x24.
+func ReadTTYRec(r io.Reader) (*Recording, error) {
+	rec := &Recording{Width: 80, Height: 24}
+	br := bufio.NewReader(r)
+	var start time.Time
+	for {
+		var h struct{ Sec, Usec, Len uint32 }
+		if err := binary.Read(br, binary.LittleEndian, &h); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("ttyrec: %v", err)
+		}
+		data := make([]byte, h.Len)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return nil, fmt.Errorf("ttyrec: %v", err)
+		}
+		ts := time.Unix(int64(h.Sec), int64(h.Usec)*1000)
+		if len(rec.Frames) == 0 {
+			start = ts
+		}
+		rec.Frames = append(rec.Frames, Frame{Time: ts.Sub(start), Data: data})
+	}
+	return rec, nil
+}
+
+// DefaultKeyframeInterval is the recording time between the states a
+// Player keeps to seek backwards.
+const DefaultKeyframeInterval = 5 * time.Second
+
+// keyframe is the state after the first n frames, as written by
+// WriteANSI.
+type keyframe struct {
+	n          int
+	cols, rows int
+	ansi       []byte
+}
+
+// Player drives a State through a Recording.
+type Player struct {
+	// Speed multiplies the playback speed of Play. Zero is 1.
+	Speed float64
+	// KeyframeInterval is the recording time between the states kept
+	// to seek backwards. Zero is DefaultKeyframeInterval.
+	KeyframeInterval time.Duration
+
+	rec       *Recording
+	vt        *VT
+	n         int           // frames applied
+	now       time.Duration // current time
+	carry     []byte        // incomplete UTF-8 sequence
+	keyframes []keyframe
+}
+
+// NewPlayer returns a Player showing the start of rec on state. The
+// state is reset.
+func NewPlayer(state *State, rec *Recording) (*Player, error) {
+	vt, err := Create(state, nil)
+	if err != nil {
+		return nil, err
+	}
+	p := &Player{rec: rec, vt: vt}
+	p.restore(keyframe{cols: rec.Width, rows: rec.Height})
+	return p, nil
+}
+
+// Time returns the current time of the playback.
+func (p *Player) Time() time.Duration {
+	return p.now
+}
+
+// Seek moves the playback to time d, applying every frame up to d. Seeking
+// backwards restarts from the latest kept state before d, which does not
+// include the history.
+func (p *Player) Seek(d time.Duration) {
+	n := sort.Search(len(p.rec.Frames), func(i int) bool {
+		return p.rec.Frames[i].Time > d
+	})
+	if n < p.n {
+		k := keyframe{cols: p.rec.Width, rows: p.rec.Height}
+		for _, kf := range p.keyframes {
+			if kf.n <= n {
+				k = kf
+			}
+		}
+		p.restore(k)
+	}
+	for p.n < n {
+		p.step()
+	}
+	p.now = d
+}
+
+// ScreenAt seeks to time d and returns the screen.
+func (p *Player) ScreenAt(d time.Duration) *Snapshot {
+	p.Seek(d)
+	p.vt.dest.lock()
+	defer p.vt.dest.unlock()
+	return p.vt.dest.Snapshot()
+}
+
+// Play applies the remaining frames at their time, scaled by Speed, until
+// the end of the recording or until ctx is done.
+func (p *Player) Play(ctx context.Context) error {
+	speed := p.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+	start, from := time.Now(), p.now
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for p.n < len(p.rec.Frames) {
+		f := p.rec.Frames[p.n]
+		wait := time.Duration(float64(f.Time-from)/speed) - time.Since(start)
+		if wait > 0 {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(wait)
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+		p.step()
+		p.now = f.Time
+	}
+	return nil
+}
+
+// step applies the next frame, keeping a keyframe if the last one is
+// older than the interval.
+func (p *Player) step() {
+	f := p.rec.Frames[p.n]
+	p.n++
+	if f.Cols != 0 {
+		p.vt.Resize(f.Cols, f.Rows)
+	} else {
+		b := append(p.carry, f.Data...)
+		n := fullRunes(b)
+		p.vt.Write(b[:n])
+		p.carry = append([]byte(nil), b[n:]...)
+	}
+
+	interval := p.KeyframeInterval
+	if interval <= 0 {
+		interval = DefaultKeyframeInterval
+	}
+	last := time.Duration(0)
+	if len(p.keyframes) > 0 {
+		last = p.rec.Frames[p.keyframes[len(p.keyframes)-1].n-1].Time
+	}
+	if f.Time-last < interval || len(p.carry) > 0 {
+		return
+	}
+	t := p.vt.dest
+	t.lock()
+	defer t.unlock()
+	if !t.idle() {
+		return
+	}
+	var b bytes.Buffer
+	t.WriteANSI(&b)
+	p.keyframes = append(p.keyframes, keyframe{n: p.n, cols: t.cols, rows: t.rows, ansi: b.Bytes()})
+}
+
+// restore resets the state to keyframe k.
+func (p *Player) restore(k keyframe) {
+	t := p.vt.dest
+	t.lock()
+	t.reset()
+	t.title = ""
+	t.state = t.parse
+	t.esc = false
+	t.resize(k.cols, k.rows)
+	t.unlock()
+	p.vt.Write(k.ansi)
+	p.n = k.n
+	p.carry = nil
+	p.now = 0
+	if k.n > 0 {
+		p.now = p.rec.Frames[k.n-1].Time
+	}
+}
+
+// idle returns true if the parser is not inside an escape sequence.
+func (t *State) idle() bool {
+	return !t.esc
+}
@@ -0,0 +1,114 @@
+package vt10x
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testCast = `{"version": 2, "width": 10, "height": 3}
+[0.5, "o", "hello"]
+[1.0, "o", "\r\nwö"]
+[1.1, "i", "x"]
+[1.5, "o", "rld"]
+[2.0, "r", "20x3"]
+[3.0, "o", "\u001b[2J\u001b[Hbye"]
+`
+
+func TestPlayer(t *testing.T) {
+	rec, err := ReadAsciicast(strings.NewReader(testCast))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Width != 10 || rec.Height != 3 || len(rec.Frames) != 5 || rec.Duration() != 3*time.Second {
+		t.Fatalf("unexpected recording %+v", rec)
+	}
+
+	var st State
+	p, err := NewPlayer(&st, rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.KeyframeInterval = time.Second
+	tests := []struct {
+		at       time.Duration
+		cols     int
+		expected string
+	}{
+		{0, 10, "\n\n"},
+		{700 * time.Millisecond, 10, "hello\n\n"},
+		{3500 * time.Millisecond, 20, "bye\n\n"},
+		{1600 * time.Millisecond, 10, "hello\nwörld\n"},
+		{2500 * time.Millisecond, 20, "hello\nwörld\n"},
+		{time.Second, 10, "hello\nwö\n"},
+	}
+	for _, test := range tests {
+		s := p.ScreenAt(test.at)
+		cols, _ := s.Size()
+		var lines []string
+		for y := 0; y < 3; y++ {
+			lines = append(lines, strings.TrimRight(s.Line(y).String(), " "))
+		}
+		if actual := strings.Join(lines, "\n"); actual != test.expected || cols != test.cols {
+			t.Fatalf("at %v: got %q with %d columns, expected %q with %d", test.at, actual, cols, test.expected, test.cols)
+		}
+	}
+	if len(p.keyframes) == 0 {
+		t.Fatal("no keyframes kept")
+	}
+
+	p.Seek(0)
+	p.Speed = 1000
+	if err := p.Play(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if st.LineText(0) != "bye" || p.Time() != 3*time.Second {
+		t.Fatalf("unexpected screen after Play %q at %v", st.LineText(0), p.Time())
+	}
+}
+
+func TestReadTTYRec(t *testing.T) {
+	var b bytes.Buffer
+	// the second frame splits "é", the third splits "世"
+	for i, data := range []string{"ab", "\r\nc\xc3", "\xa9d\xe4\xb8", "\x96界"} {
+		binary.Write(&b, binary.LittleEndian, []uint32{100, uint32(i * 250000), uint32(len(data))})
+		b.WriteString(data)
+	}
+	rec, err := ReadTTYRec(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.Frames) != 4 || rec.Frames[3].Time != 750*time.Millisecond {
+		t.Fatalf("unexpected recording %+v", rec)
+	}
+	var st State
+	p, _ := NewPlayer(&st, rec)
+	p.Seek(time.Second)
+	if st.String() != "ab\ncéd世界"+strings.Repeat("\n", 22) {
+		t.Fatalf("unexpected screen %q", st.String())
+	}
+}
+
+func TestParserIdle(t *testing.T) {
+	var st State
+	term, _ := Create(&st, nil)
+	term.Write([]byte("\033["))
+	if st.idle() {
+		t.Fatal("idle inside an escape sequence")
+	}
+	term.Write([]byte("m"))
+	if !st.idle() {
+		t.Fatal("not idle after an escape sequence")
+	}
+	term.Write([]byte("\033]0;title\033"))
+	if st.idle() {
+		t.Fatal("idle inside a string sequence")
+	}
+	term.Write([]byte("\\\0337"))
+	if !st.idle() {
+		t.Fatal("not idle after a string and an ESC sequence")
+	}
+}
@@ -0,0 +1,188 @@
+package vt10x
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// RecordOptions control a recording made with VT.Record.
+type RecordOptions struct {
+	// Input records the input sent with SendKey, SendMouse, Paste and
+	// SetFocused as "i" events.
+	Input bool
+	// Title, Command and Env are written to the header if set.
+	Title   string
+	Command string
+	Env     map[string]string
+}
+
+// Recorder writes the session of a VT in the asciicast v2 format: a JSON
+// header line followed by one JSON array per event, holding the time since
+// the start of the recording, the event type and its data.
+type Recorder struct {
+	vt    *VT
+	input bool
+	start time.Time
+
+	mu      sync.Mutex
+	w       io.Writer
+	err     error
+	pending map[string][]byte // incomplete UTF-8 sequences per event type
+}
+
+type asciicastHeader struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Command   string            `json:"command,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Record starts recording the session to w, replacing any previous
+// recording. The output of the application, read by Parse or given to
+// Write, is recorded as "o" events and calls to Resize as "r" events.
+// The header holds the current size of the terminal.
+func (t *VT) Record(w io.Writer, opts RecordOptions) (*Recorder, error) {
+	r := &Recorder{
+		vt:      t,
+		input:   opts.Input,
+		start:   time.Now(),
+		w:       w,
+		pending: make(map[string][]byte),
+	}
+	t.dest.lock()
+	cols, rows := t.dest.cols, t.dest.rows
+	t.dest.unlock()
+	err := r.writeJSON(asciicastHeader{
+		Version:   2,
+		Width:     cols,
+		Height:    rows,
+		Timestamp: r.start.Unix(),
+		Title:     opts.Title,
+		Command:   opts.Command,
+		Env:       opts.Env,
+	})
+	if err != nil {
+		return nil, err
+	}
+	t.recMu.Lock()
+	t.rec = r
+	t.recMu.Unlock()
+	return r, nil
+}
+
+// Close stops the recording and returns the first error writing it. It
+// does not close the underlying writer.
+func (r *Recorder) Close() error {
+	r.vt.recMu.Lock()
+	if r.vt.rec == r {
+		r.vt.rec = nil
+	}
+	r.vt.recMu.Unlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, code := range []string{"o", "i"} {
+		if p := r.pending[code]; len(p) > 0 {
+			r.pending[code] = nil
+			r.writeEvent(code, string(p))
+		}
+	}
+	return r.err
+}
+
+// event records data of event type code, holding back an incomplete UTF-8
+// sequence at its end until the next event of the same type.
+func (r *Recorder) event(code string, p []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p = append(r.pending[code], p...)
+	n := fullRunes(p)
+	r.pending[code] = append([]byte(nil), p[n:]...)
+	if n > 0 {
+		r.writeEvent(code, string(p[:n]))
+	}
+}
+
+// fullRunes returns the length of p without an incomplete UTF-8 sequence
+// at its end.
+func fullRunes(p []byte) int {
+	for i := 1; i <= utf8.UTFMax && i <= len(p); i++ {
+		if utf8.RuneStart(p[len(p)-i]) {
+			if !utf8.FullRune(p[len(p)-i:]) {
+				return len(p) - i
+			}
+			break
+		}
+	}
+	return len(p)
+}
+
+func (r *Recorder) writeEvent(code, data string) {
+	ts := json.Number(fmt.Sprintf("%.6f", time.Since(r.start).Seconds()))
+	r.writeJSON([]interface{}{ts, code, data})
+}
+
+// writeJSON writes v on a line, keeping the first error. The caller holds
+// the lock, except for the header.
+func (r *Recorder) writeJSON(v interface{}) error {
+	if r.err != nil {
+		return r.err
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		r.err = err
+		return err
+	}
+	_, r.err = r.w.Write(b.Bytes())
+	return r.err
+}
+
+func (t *VT) recorder() *Recorder {
+	t.recMu.Lock()
+	defer t.recMu.Unlock()
+	return t.rec
+}
+
+func (t *VT) recordOutput(p []byte) {
+	if r := t.recorder(); r != nil && len(p) > 0 {
+		r.event("o", p)
+	}
+}
+
+func (t *VT) recordInput(p []byte) {
+	if r := t.recorder(); r != nil && r.input && len(p) > 0 {
+		r.event("i", p)
+	}
+}
+
+func (t *VT) recordResize(cols, rows int) {
+	if r := t.recorder(); r != nil {
+		r.mu.Lock()
+		r.writeEvent("r", fmt.Sprintf("%dx%d", cols, rows))
+		r.mu.Unlock()
+	}
+}
+
+// readFunc adapts a function to io.Reader.
+type readFunc func(p []byte) (int, error)
+
+func (f readFunc) Read(p []byte) (int, error) {
+	return f(p)
+}
+
+// read reads the output of the application, recording it.
+func (t *VT) read(p []byte) (int, error) {
+	n, err := t.rc.Read(p)
+	t.recordOutput(p[:n])
+	return n, err
+}
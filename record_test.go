@@ -0,0 +1,80 @@
+package vt10x
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// chunkReader returns one chunk per Read.
+type chunkReader struct {
+	chunks []string
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, io.EOF
+	}
+	n := copy(p, r.chunks[0])
+	r.chunks = r.chunks[1:]
+	return n, nil
+}
+
+func TestRecord(t *testing.T) {
+	var st State
+	// the output splits "é"
+	rc := ioutil.NopCloser(&chunkReader{[]string{"h\xc3", "\xa9llo\r\n"}})
+	term, err := Create(&st, rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	term.Resize(40, 10)
+	term.SetOutput(ioutil.Discard)
+
+	var b bytes.Buffer
+	rec, err := term.Record(&b, RecordOptions{Input: true, Title: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for term.Parse() == nil {
+	}
+	term.Write([]byte("<ok>"))
+	term.Resize(30, 5)
+	term.SendKey(KeyEvent{Key: KeyUp})
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// nothing is recorded after Close
+	term.Write([]byte("x"))
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	var header map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &header); err != nil {
+		t.Fatal(err)
+	}
+	if header["version"] != 2.0 || header["width"] != 40.0 || header["height"] != 10.0 || header["title"] != "test" {
+		t.Fatalf("unexpected header %s", lines[0])
+	}
+	expected := [][2]string{
+		{"o", "h"},
+		{"o", "éllo\r\n"},
+		{"o", "<ok>"},
+		{"r", "30x5"},
+		{"i", "\033[A"},
+	}
+	if len(lines)-1 != len(expected) {
+		t.Fatalf("got %d events, expected %d:\n%s", len(lines)-1, len(expected), b.String())
+	}
+	for i, line := range lines[1:] {
+		var ev []interface{}
+		if err := json.Unmarshal([]byte(line), &ev); err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := ev[0].(float64); !ok || ev[1] != expected[i][0] || ev[2] != expected[i][1] {
+			t.Fatalf("event %d is %s, expected %q", i, line, expected[i])
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package vt10x
+
+// blankGlyph is an erased cell with default attributes.
+var blankGlyph = Glyph{Char: ' ', Fg: DefaultFG, Bg: DefaultBG, Ul: DefaultUL}
+
+// isBlank returns true if g holds no character, whatever its colors.
+// Trailing runs of blanks are dropped from lines before they are rewrapped.
+func isBlank(g Glyph) bool {
+	return g.Char == ' ' && g.Comb == "" && g.Mode&(attrWide|attrWDummy) == 0
+}
+
+// reflow rewraps the history and the primary screen to cols columns and
+// rows rows, joining the rows soft wrapped by attrWrap. The history is
+// replaced by the rows that no longer fit above the cell at (cx, cy), so
+// growing the screen brings lines back from the history. It returns the new
+// rows of the screen and the new position of the cell at (cx, cy), whose
+// column is cols if it falls right after a full row.
+func (t *State) reflow(cols, rows, cx, cy int) ([]Line, int, int) {
+	screen := t.lines
+	if t.mode&ModeAltScreen != 0 {
+		screen = t.altLines
+	}
+	last := cy
+	for y := len(screen) - 1; y > last; y-- {
+		if !blankLine(screen[y]) {
+			last = y
+		}
+	}
+
+	// join soft wrapped rows into logical lines
+	var logical []Line
+	var cur Line
+	curLine, curOff := 0, 0
+	add := func(l Line, cursor bool) {
+		if cursor {
+			curLine, curOff = len(logical), len(cur)+cx
+		}
+		n := len(l)
+		wrapped := n > 0 && l[n-1].Mode&attrWrap != 0
+		if !wrapped {
+			for n > 0 && isBlank(l[n-1]) {
+				n--
+			}
+			if cursor && n <= cx {
+				n = min(cx+1, len(l))
+			}
+		}
+		// drop the blank left by a double width character wrapped early
+		if k := len(cur) - 1; k >= 0 && isBlank(cur[k]) && n > 0 && l[0].Mode&attrWide != 0 {
+			cur = cur[:k]
+			if cursor {
+				curOff--
+			}
+		}
+		start := len(cur)
+		cur = append(cur, l[:n]...)
+		for i := start; i < len(cur); i++ {
+			cur[i].Mode &^= attrWrap
+		}
+		if !wrapped {
+			logical = append(logical, cur)
+			cur = nil
+		}
+	}
+	for i := 0; i < t.history.len(); i++ {
+		add(t.history.at(i), false)
+	}
+	for y := 0; y <= last; y++ {
+		add(screen[y], y == cy)
+	}
+	if cur != nil {
+		logical = append(logical, cur)
+	}
+
+	// wrap the logical lines at the new width
+	var out []Line
+	var row Line
+	newx, newy := 0, 0
+	for i, l := range logical {
+		row = newRow(cols)
+		x := 0
+		for j := 0; j < len(l); {
+			g, w, next := l[j], 1, j+1
+			var dummy Glyph
+			switch {
+			case g.Mode&attrWide != 0 && next < len(l):
+				w, dummy, next = 2, l[next], next+1
+			case g.Mode&attrWDummy == 0 && runeWidth(g.Char) == 2:
+				// a wide character narrowed on a single column regains
+				// its second cell
+				g.Mode |= attrWide
+				w, dummy = 2, g
+				dummy.Char, dummy.Comb = 0, ""
+				dummy.Mode = g.Mode&^attrWide | attrWDummy
+			}
+			if w > cols {
+				g.Mode &^= attrWide
+				w = 1
+			}
+			if x+w > cols {
+				row[cols-1].Mode |= attrWrap
+				out = append(out, row)
+				row, x = newRow(cols), 0
+			}
+			if i == curLine && between(curOff, j, next-1) {
+				newx, newy = x+min(curOff-j, w-1), len(out)
+			}
+			row[x] = g
+			if w == 2 {
+				row[x+1] = dummy
+			}
+			x += w
+			j = next
+		}
+		if i == curLine && curOff >= len(l) {
+			newx, newy = min(x+curOff-len(l), cols), len(out)
+		}
+		out = append(out, row)
+	}
+
+	// keep the cursor on the screen, pushing what is above to the history
+	start := max(0, min(len(out)-rows, newy))
+	t.history.reset()
+	t.pushHistory(out[:start]...)
+	lines := make([]Line, rows)
+	for y := range lines {
+		if start+y < len(out) {
+			lines[y] = out[start+y]
+		} else {
+			lines[y] = newRow(cols)
+		}
+	}
+	return lines, newx, newy - start
+}
+
+func newRow(cols int) Line {
+	l := make(Line, cols)
+	for i := range l {
+		l[i] = blankGlyph
+	}
+	return l
+}
+
+func blankLine(l Line) bool {
+	for _, g := range l {
+		if !isBlank(g) {
+			return false
+		}
+	}
+	return true
+}
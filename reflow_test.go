@@ -0,0 +1,162 @@
+package vt10x
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestReflow(t *testing.T) {
+	st := State{MaxHistory: 10}
+	term, err := Create(&st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	term.Resize(10, 4)
+	term.Write([]byte("0123456789abcde\r\nxy"))
+
+	tests := []struct {
+		cols    int
+		history []string
+		lines   []string
+		cx, cy  int
+	}{
+		{20, nil, []string{"0123456789abcde", "xy", "", ""}, 2, 1},
+		{5, nil, []string{"01234", "56789", "abcde", "xy"}, 2, 3},
+		{4, []string{"0123"}, []string{"4567", "89ab", "cde", "xy"}, 2, 3},
+		{10, nil, []string{"0123456789", "abcde", "xy", ""}, 2, 2},
+	}
+	for _, test := range tests {
+		term.Resize(test.cols, 4)
+		if n := st.HistoryLen(); n != len(test.history) {
+			t.Fatalf("%d columns: history length %d, expected %d", test.cols, n, len(test.history))
+		}
+		for i, expected := range test.history {
+			if actual := st.HistoryLine(i).String(); len(actual) != test.cols || actual[:len(expected)] != expected {
+				t.Fatalf("%d columns: history line %d is %q, expected %q", test.cols, i, actual, expected)
+			}
+		}
+		for y, expected := range test.lines {
+			if actual := st.LineText(y); actual != expected {
+				t.Fatalf("%d columns: line %d is %q, expected %q", test.cols, y, actual, expected)
+			}
+		}
+		if x, y := st.Cursor(); x != test.cx || y != test.cy {
+			t.Fatalf("%d columns: cursor at (%d, %d), expected (%d, %d)", test.cols, x, y, test.cx, test.cy)
+		}
+	}
+
+	// double width characters are not split
+	term.Write([]byte("\033[H\033[2J\033[3Jab世c"))
+	term.Resize(3, 4)
+	if l0, l1 := st.LineText(0), st.LineText(1); l0 != "ab" || l1 != "世c" {
+		t.Fatalf("wide characters: got %q and %q", l0, l1)
+	}
+
+	// a single column narrows wide characters, which widen again after
+	term.Write([]byte("\033[H\033[2J\033[3Jab世界cd"))
+	term.Resize(1, 4)
+	term.Resize(10, 4)
+	if l0 := st.LineText(0); l0 != "ab世界cd" || st.lines[0][2].Width() != 2 || st.lines[0][6].Char != 'c' {
+		t.Fatalf("wide characters after a single column: got %q", l0)
+	}
+
+	// the alt screen is cut, not reflowed
+	term.Resize(10, 4)
+	term.Write([]byte("\033[?1049h\033[H0123456789abc"))
+	term.Resize(5, 4)
+	if l0, l1 := st.LineText(0), st.LineText(1); l0 != "01234" || l1 != "abc" {
+		t.Fatalf("alt screen: got %q and %q", l0, l1)
+	}
+	term.Write([]byte("\033[?1049l"))
+	if l0, l1 := st.LineText(0), st.LineText(1); l0 != "ab世" || l1 != "界cd" {
+		t.Fatalf("primary screen after alt screen: got %q and %q", l0, l1)
+	}
+}
+
+func TestReflowRows(t *testing.T) {
+	st := State{MaxHistory: 100}
+	term, err := Create(&st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	term.Resize(80, 24)
+	for i := 0; i < 30; i++ {
+		fmt.Fprintf(term, "\r\nline %d", i)
+	}
+	before := st.String()
+	history := st.HistoryLen()
+
+	term.Resize(80, 10)
+	if st.LineText(9) != "line 29" || st.HistoryLen() != history+14 {
+		t.Fatalf("shrunk screen ends with %q and %d history lines", st.LineText(9), st.HistoryLen())
+	}
+	// growing the screen brings the lines back from the history
+	term.Resize(80, 24)
+	if st.String() != before || st.HistoryLen() != history {
+		t.Fatalf("got screen\n%s\nwith %d history lines, expected\n%s\nwith %d", st.String(), st.HistoryLen(), before, history)
+	}
+	if x, y := st.Cursor(); x != 7 || y != 23 {
+		t.Fatalf("cursor at (%d, %d), expected (7, 23)", x, y)
+	}
+}
+
+func TestReflowColoredBlanks(t *testing.T) {
+	st := State{MaxHistory: 10}
+	term, err := Create(&st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	term.Resize(20, 4)
+	// blanks erased with a background color and the graphics charset
+	term.Write([]byte("\033[44m\033(0\033[2J\033(B\033[1;1Hhi\033[2;1Hthere"))
+	term.Resize(10, 4)
+	if st.HistoryLen() != 0 || st.LineText(0) != "hi" || st.LineText(1) != "there" {
+		t.Fatalf("got %q and %q with %d history lines", st.LineText(0), st.LineText(1), st.HistoryLen())
+	}
+}
+
+func TestReflowAltScreen(t *testing.T) {
+	for _, mode := range []string{"47", "1047"} {
+		st := State{MaxHistory: 10}
+		term, err := Create(&st, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		term.Resize(10, 6)
+		// the alt screen saves its own cursor at the top
+		term.Write([]byte("a\r\nb\r\nc\r\nd\r\ne\r\n$ \033[?" + mode + "h\033[H\0337"))
+		term.Resize(10, 3)
+		term.Write([]byte("\033[?" + mode + "l"))
+		if s := st.String(); s != "d\ne\n$" || st.HistoryLen() != 3 {
+			t.Fatalf("%s: got %q with %d history lines", mode, s, st.HistoryLen())
+		}
+	}
+}
+
+func TestReflowPendingWrap(t *testing.T) {
+	tests := []struct {
+		cols     int
+		expected string
+	}{
+		{20, "0123456789X"},
+		{5, "01234\n56789\nX"},
+		{10, "0123456789\nX"},
+	}
+	for _, test := range tests {
+		var st State
+		term, err := Create(&st, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		term.Resize(10, 4)
+		// the full row leaves a wrap pending
+		term.Write([]byte("0123456789"))
+		term.Resize(test.cols, 4)
+		term.Resize(test.cols, 3)
+		term.Write([]byte("X"))
+		if actual := strings.TrimRight(st.String(), "\n"); actual != test.expected {
+			t.Fatalf("%d columns: got %q, expected %q", test.cols, actual, test.expected)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package vt10x
+
+import (
+	"strings"
+)
+
+// SelectMode is how a selection snaps to the text under it.
+type SelectMode uint8
+
+// Selection modes
+const (
+	SelectRegular SelectMode = iota // characters in reading order
+	SelectWord                      // whole words, see State.WordDelimiters
+	SelectLine                      // whole lines, following soft wraps
+	SelectBlock                     // a rectangle of cells
+)
+
+// DefaultWordDelimiters are the characters separating words when
+// State.WordDelimiters is empty.
+const DefaultWordDelimiters = " \t\"'`()[]{}<>|;,"
+
+type point struct {
+	x, y int
+}
+
+// selection mirrors st's: ob and oe are where the selection began and
+// currently ends, nb and ne the normalized and snapped first and last cells.
+type selection struct {
+	active bool
+	alt    bool // made on the alt screen
+	mode   SelectMode
+	ob, oe point
+	nb, ne point
+}
+
+// StartSelection begins a selection at cell (x, y), replacing any previous
+// one.
+func (t *State) StartSelection(x, y int, mode SelectMode) {
+	t.ClearSelection()
+	p := point{clamp(x, 0, t.cols-1), clamp(y, 0, t.rows-1)}
+	t.sel = selection{
+		active: true,
+		alt:    t.mode&ModeAltScreen != 0,
+		mode:   mode,
+		ob:     p,
+		oe:     p,
+	}
+	t.normalizeSelection()
+	t.dirtySelection()
+}
+
+// ExtendSelection moves the end of the current selection to cell (x, y).
+func (t *State) ExtendSelection(x, y int) {
+	if !t.sel.active {
+		return
+	}
+	t.dirtySelection()
+	t.sel.oe = point{clamp(x, 0, t.cols-1), clamp(y, 0, t.rows-1)}
+	t.normalizeSelection()
+	t.dirtySelection()
+}
+
+// ClearSelection removes the current selection.
+func (t *State) ClearSelection() {
+	if !t.sel.active {
+		return
+	}
+	t.dirtySelection()
+	t.sel.active = false
+}
+
+// IsSelected returns true if cell (x, y) is part of the selection.
+func (t *State) IsSelected(x, y int) bool {
+	s := &t.sel
+	if !s.active || s.alt != (t.mode&ModeAltScreen != 0) {
+		return false
+	}
+	if s.mode == SelectBlock {
+		return between(y, s.nb.y, s.ne.y) && between(x, s.nb.x, s.ne.x)
+	}
+	return between(y, s.nb.y, s.ne.y) &&
+		(y != s.nb.y || x >= s.nb.x) &&
+		(y != s.ne.y || x <= s.ne.x)
+}
+
+// SelectedText returns the text of the selection. Rows are separated by
+// newlines, except for rows continued by a soft wrap, which are joined.
+// Trailing blanks are removed from rows ending in a hard newline.
+func (t *State) SelectedText() string {
+	s := &t.sel
+	if !s.active || s.alt != (t.mode&ModeAltScreen != 0) {
+		return ""
+	}
+	var b strings.Builder
+	for y := s.nb.y; y <= s.ne.y; y++ {
+		n := t.lineLen(y)
+		if n == 0 {
+			b.WriteByte('\n')
+			continue
+		}
+		var first, lastx int
+		if s.mode == SelectBlock {
+			first, lastx = s.nb.x, s.ne.x
+		} else {
+			if y == s.nb.y {
+				first = s.nb.x
+			}
+			lastx = t.cols - 1
+			if y == s.ne.y {
+				lastx = s.ne.x
+			}
+		}
+		wrapped := t.lines[y][t.cols-1].Mode&attrWrap != 0
+		last := min(lastx, n-1)
+		for !wrapped && last >= first && t.lines[y][last].Char == ' ' {
+			last--
+		}
+		for x := first; x <= last; x++ {
+			b.WriteString(t.lines[y][x].String())
+		}
+		if (y < s.ne.y || lastx >= n) && (!wrapped || s.mode == SelectBlock) {
+			b.WriteByte('\n')
+		}
+	}
+	return b.String()
+}
+
+// lineLen returns the length of row y without trailing blanks, or the full
+// width if the row continues on the next.
+func (t *State) lineLen(y int) int {
+	i := t.cols
+	if t.lines[y][i-1].Mode&attrWrap != 0 {
+		return i
+	}
+	for i > 0 && t.lines[y][i-1].Char == ' ' {
+		i--
+	}
+	return i
+}
+
+func (t *State) isDelim(c rune) bool {
+	delims := t.WordDelimiters
+	if delims == "" {
+		delims = DefaultWordDelimiters
+	}
+	return c != 0 && strings.ContainsRune(delims, c)
+}
+
+func (t *State) normalizeSelection() {
+	s := &t.sel
+	if s.mode != SelectBlock && s.ob.y != s.oe.y {
+		if s.ob.y < s.oe.y {
+			s.nb.x, s.ne.x = s.ob.x, s.oe.x
+		} else {
+			s.nb.x, s.ne.x = s.oe.x, s.ob.x
+		}
+	} else {
+		s.nb.x = min(s.ob.x, s.oe.x)
+		s.ne.x = max(s.ob.x, s.oe.x)
+	}
+	s.nb.y = min(s.ob.y, s.oe.y)
+	s.ne.y = max(s.ob.y, s.oe.y)
+
+	t.snapSelection(&s.nb, -1)
+	t.snapSelection(&s.ne, 1)
+
+	// expand the selection over line breaks
+	if s.mode == SelectBlock {
+		return
+	}
+	if n := t.lineLen(s.nb.y); n < s.nb.x {
+		s.nb.x = n
+	}
+	if t.lineLen(s.ne.y) <= s.ne.x {
+		s.ne.x = t.cols - 1
+	}
+}
+
+// snapSelection moves p in direction to the word or line boundary,
+// following soft wraps.
+func (t *State) snapSelection(p *point, direction int) {
+	switch t.sel.mode {
+	case SelectWord:
+		prev := t.lines[p.y][p.x]
+		prevDelim := t.isDelim(prev.Char)
+		for {
+			x, y := p.x+direction, p.y
+			if !between(x, 0, t.cols-1) {
+				y += direction
+				x = (x + t.cols) % t.cols
+				if !between(y, 0, t.rows-1) {
+					break
+				}
+				wy := p.y
+				if direction < 0 {
+					wy = y
+				}
+				if t.lines[wy][t.cols-1].Mode&attrWrap == 0 {
+					break
+				}
+			}
+			if x >= t.lineLen(y) {
+				break
+			}
+			g := t.lines[y][x]
+			delim := t.isDelim(g.Char)
+			if g.Mode&attrWDummy == 0 && (delim != prevDelim || (delim && g.Char != prev.Char)) {
+				break
+			}
+			p.x, p.y = x, y
+			prev, prevDelim = g, delim
+		}
+	case SelectLine:
+		if direction < 0 {
+			p.x = 0
+			for p.y > 0 && t.lines[p.y-1][t.cols-1].Mode&attrWrap != 0 {
+				p.y--
+			}
+		} else {
+			p.x = t.cols - 1
+			for p.y < t.rows-1 && t.lines[p.y][t.cols-1].Mode&attrWrap != 0 {
+				p.y++
+			}
+		}
+	}
+}
+
+// scrollSelection moves the selection along with rows [orig, bottom]
+// scrolled by n, clearing it when it leaves the scroll region or is cut by
+// its edge.
+func (t *State) scrollSelection(orig, n int) {
+	s := &t.sel
+	if !s.active || s.alt != (t.mode&ModeAltScreen != 0) {
+		return
+	}
+	if between(s.nb.y, orig, t.bottom) != between(s.ne.y, orig, t.bottom) {
+		t.ClearSelection()
+	} else if between(s.nb.y, orig, t.bottom) {
+		s.ob.y += n
+		s.oe.y += n
+		if !between(s.ob.y, t.top, t.bottom) || !between(s.oe.y, t.top, t.bottom) {
+			t.ClearSelection()
+		} else {
+			t.normalizeSelection()
+			t.changed |= ChangedSelection
+		}
+	}
+}
+
+func (t *State) dirtySelection() {
+	t.changed |= ChangedSelection
+	for y := t.sel.nb.y; y <= t.sel.ne.y && y < t.rows; y++ {
+		t.Dirty[y] = true
+	}
+}
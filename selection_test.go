@@ -0,0 +1,88 @@
+package vt10x
+
+import (
+	"testing"
+)
+
+func TestSelection(t *testing.T) {
+	var st State
+	term, err := Create(&st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	term.Resize(10, 4)
+	// the second line soft wraps onto the third
+	term.Write([]byte("foo bar\r\nhello world (x)\r\nlast"))
+
+	tests := []struct {
+		mode     SelectMode
+		x0, y0   int
+		x1, y1   int
+		expected string
+	}{
+		{SelectRegular, 4, 0, 5, 0, "ba"},
+		{SelectRegular, 4, 0, 2, 1, "bar\nhel"},
+		{SelectRegular, 6, 1, 3, 3, "world (x)\nlast"},
+		{SelectRegular, 6, 1, 9, 3, "world (x)\nlast\n"},
+		{SelectRegular, 9, 0, 9, 0, "\n"},
+		{SelectWord, 7, 1, 7, 1, "world"},
+		{SelectWord, 1, 0, 5, 0, "foo bar"},
+		{SelectWord, 3, 2, 3, 2, "x"},
+		{SelectLine, 3, 2, 3, 2, "hello world (x)\n"},
+		{SelectBlock, 1, 0, 3, 1, "oo\nell"},
+	}
+	for _, test := range tests {
+		st.StartSelection(test.x0, test.y0, test.mode)
+		st.ExtendSelection(test.x1, test.y1)
+		if actual := st.SelectedText(); actual != test.expected {
+			t.Fatalf("%+v: got %q, expected %q", test, actual, test.expected)
+		}
+	}
+
+	st.StartSelection(0, 1, SelectRegular)
+	st.ExtendSelection(4, 1)
+	if !st.IsSelected(2, 1) || st.IsSelected(5, 1) || st.IsSelected(2, 0) {
+		t.Fatal("unexpected selected cells")
+	}
+
+	// the selection follows the text when the screen scrolls
+	term.Write([]byte("\r\n"))
+	if !st.IsSelected(2, 0) || st.SelectedText() != "hello" {
+		t.Fatalf("selection did not scroll, got %q", st.SelectedText())
+	}
+	// and is cleared when scrolled off the screen
+	term.Write([]byte("\r\n"))
+	if st.SelectedText() != "" {
+		t.Fatal("selection not cleared when scrolled off")
+	}
+
+	// overwriting a selected cell clears the selection
+	st.StartSelection(0, 0, SelectLine)
+	term.Write([]byte("\033[H\033[3Cx"))
+	if st.IsSelected(0, 0) {
+		t.Fatal("selection not cleared when overwritten")
+	}
+
+	// the check is made on the cell written after a pending wrap
+	term.Write([]byte("\033[2J\033[H0123456789"))
+	st.StartSelection(0, 1, SelectRegular)
+	st.ExtendSelection(3, 1)
+	term.Write([]byte("a"))
+	if st.IsSelected(0, 1) {
+		t.Fatal("selection not cleared when overwritten after a wrap")
+	}
+	// and on both cells of a wide character
+	st.StartSelection(5, 1, SelectRegular)
+	st.ExtendSelection(6, 1)
+	term.Write([]byte("\033[2;5H世"))
+	if st.IsSelected(5, 1) {
+		t.Fatal("selection not cleared when overwritten by a wide character")
+	}
+
+	// selections are made per screen
+	st.StartSelection(0, 0, SelectLine)
+	term.Write([]byte("\033[?1049h"))
+	if st.IsSelected(0, 0) {
+		t.Fatal("primary screen selection shown on alt screen")
+	}
+}
@@ -0,0 +1,125 @@
+package vt10x
+
+// Snapshot is an immutable copy of the visible screen of a State.
+type Snapshot struct {
+	cols, rows int
+	lines      []Line
+	curX, curY int
+	curVisible bool
+	mode       ModeFlag
+	title      string
+}
+
+// Snapshot returns a copy of the visible screen, cursor, modes and title.
+func (t *State) Snapshot() *Snapshot {
+	s := &Snapshot{
+		cols:       t.cols,
+		rows:       t.rows,
+		lines:      make([]Line, t.rows),
+		curX:       t.Cur.x,
+		curY:       t.Cur.y,
+		curVisible: t.CursorVisible(),
+		mode:       t.mode,
+		title:      t.title,
+	}
+	for y := range s.lines {
+		s.lines[y] = append(Line(nil), t.lines[y]...)
+	}
+	return s
+}
+
+// Size returns the number of columns and rows of the screen.
+func (s *Snapshot) Size() (cols, rows int) {
+	return s.cols, s.rows
+}
+
+// Cell returns the glyph at position (x, y).
+func (s *Snapshot) Cell(x, y int) Glyph {
+	return s.lines[y][x]
+}
+
+// Line returns a copy of row y.
+func (s *Snapshot) Line(y int) Line {
+	return append(Line(nil), s.lines[y]...)
+}
+
+// Cursor returns the position of the cursor.
+func (s *Snapshot) Cursor() (x, y int) {
+	return s.curX, s.curY
+}
+
+// CursorVisible returns the visible state of the cursor.
+func (s *Snapshot) CursorVisible() bool {
+	return s.curVisible
+}
+
+// Mode tests if mode was set.
+func (s *Snapshot) Mode(mode ModeFlag) bool {
+	return s.mode&mode != 0
+}
+
+// Title returns the title.
+func (s *Snapshot) Title() string {
+	return s.title
+}
+
+// CellRun is a run of consecutive changed cells of a row. A run never
+// holds only one half of a double width character.
+type CellRun struct {
+	Row, Col int
+	Glyphs   []Glyph // the new cells from Col on
+}
+
+// SnapshotDiff holds the changes between two snapshots.
+type SnapshotDiff struct {
+	// Runs are the changed cells, top to bottom and left to right. If the
+	// size changed, they cover the whole new screen.
+	Runs                    []CellRun
+	Resized                 bool
+	CursorMoved             bool
+	CursorVisibilityChanged bool
+	Modes                   ModeFlag // the modes that were set or reset
+	TitleChanged            bool
+}
+
+// Empty returns true if nothing changed.
+func (d *SnapshotDiff) Empty() bool {
+	return len(d.Runs) == 0 && !d.Resized && !d.CursorMoved &&
+		!d.CursorVisibilityChanged && d.Modes == 0 && !d.TitleChanged
+}
+
+// Diff returns the changes turning snapshot a into snapshot b.
+func Diff(a, b *Snapshot) SnapshotDiff {
+	d := SnapshotDiff{
+		Resized:                 a.cols != b.cols || a.rows != b.rows,
+		CursorMoved:             a.curX != b.curX || a.curY != b.curY,
+		CursorVisibilityChanged: a.curVisible != b.curVisible,
+		Modes:                   a.mode ^ b.mode,
+		TitleChanged:            a.title != b.title,
+	}
+	for y, l := range b.lines {
+		if d.Resized {
+			d.Runs = append(d.Runs, CellRun{Row: y, Glyphs: append([]Glyph(nil), l...)})
+			continue
+		}
+		for x := 0; x < b.cols; {
+			if l[x] == a.lines[y][x] {
+				x++
+				continue
+			}
+			start := x
+			for x < b.cols && l[x] != a.lines[y][x] {
+				x++
+			}
+			// keep both halves of double width characters
+			if start > 0 && l[start].Mode&attrWDummy != 0 {
+				start--
+			}
+			if x < b.cols && l[x-1].Mode&attrWide != 0 {
+				x++
+			}
+			d.Runs = append(d.Runs, CellRun{Row: y, Col: start, Glyphs: append([]Glyph(nil), l[start:x]...)})
+		}
+	}
+	return d
+}
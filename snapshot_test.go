@@ -0,0 +1,59 @@
+package vt10x
+
+import (
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	var st State
+	term, err := Create(&st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	term.Resize(10, 3)
+	term.Write([]byte("hello\r\nworld"))
+	a := st.Snapshot()
+	if d := Diff(a, st.Snapshot()); !d.Empty() {
+		t.Fatalf("unexpected changes %+v", d)
+	}
+
+	term.Write([]byte("\033[1;2HE\033[2;5H世\033[?25l\033[?1h\033]0;t\a"))
+	b := st.Snapshot()
+	if a.Cell(1, 0).Char != 'e' || b.Cell(1, 0).Char != 'E' {
+		t.Fatal("snapshot changed after the screen")
+	}
+	d := Diff(a, b)
+	if len(d.Runs) != 2 {
+		t.Fatalf("got %d runs, expected 2: %+v", len(d.Runs), d.Runs)
+	}
+	tests := []struct {
+		row, col int
+		text     string
+	}{
+		{0, 1, "E"},
+		{1, 4, "世"},
+	}
+	for i, test := range tests {
+		r := d.Runs[i]
+		if r.Row != test.row || r.Col != test.col || Line(r.Glyphs).String() != test.text {
+			t.Fatalf("run %d: %+v, expected %+v", i, r, test)
+		}
+	}
+	if !d.CursorMoved || !d.CursorVisibilityChanged || d.Modes != ModeAppCursor|ModeHide ||
+		!d.TitleChanged || d.Resized {
+		t.Fatalf("unexpected changes %+v", d)
+	}
+
+	// replacing the placeholder half of a wide character includes the
+	// first half in the run
+	term.Write([]byte("\033[2;6Hx"))
+	d = Diff(b, st.Snapshot())
+	if len(d.Runs) != 1 || d.Runs[0].Col != 4 || Line(d.Runs[0].Glyphs).String() != " x" {
+		t.Fatalf("unexpected runs %+v", d.Runs)
+	}
+
+	term.Resize(8, 3)
+	if d := Diff(b, st.Snapshot()); !d.Resized || len(d.Runs) != 3 {
+		t.Fatalf("unexpected changes after resize %+v", d)
+	}
+}
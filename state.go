@@ -1,12 +1,16 @@
 package vt10x
 
 import (
+	"fmt"
+	"io"
 	"log"
+	"strings"
 	"sync"
 )
 
 const (
-	tabspaces = 8
+	tabspaces    = 8
+	maxCombining = 16 // zero width code points kept per cell
 )
 
 const (
@@ -17,6 +21,42 @@ const (
 	attrItalic
 	attrBlink
 	attrWrap
+	attrWide   // first cell of a double width character
+	attrWDummy // placeholder cell following a double width character
+	attrFaint
+	attrInvisible
+	attrStrike
+	attrDoubleUnderline
+	attrOverline
+	attrCurlyUnderline
+	attrDottedUnderline
+	attrDashedUnderline
+)
+
+// Attr is a set of character attributes of a Glyph.
+type Attr uint32
+
+// Character attributes set by SGR. The colors of a reversed Glyph are
+// already swapped, so AttrReverse is informational.
+const (
+	AttrReverse         Attr = attrReverse
+	AttrUnderline       Attr = attrUnderline
+	AttrBold            Attr = attrBold
+	AttrItalic          Attr = attrItalic
+	AttrBlink           Attr = attrBlink
+	AttrFaint           Attr = attrFaint
+	AttrInvisible       Attr = attrInvisible
+	AttrStrike          Attr = attrStrike
+	AttrDoubleUnderline Attr = attrDoubleUnderline
+	AttrCurlyUnderline  Attr = attrCurlyUnderline
+	AttrDottedUnderline Attr = attrDottedUnderline
+	AttrDashedUnderline Attr = attrDashedUnderline
+	AttrOverline        Attr = attrOverline
+
+	attrUnderlines = AttrUnderline | AttrDoubleUnderline | AttrCurlyUnderline |
+		AttrDottedUnderline | AttrDashedUnderline
+	attrSGR = AttrReverse | AttrBold | AttrItalic | AttrBlink | AttrFaint |
+		AttrInvisible | AttrStrike | AttrOverline | attrUnderlines
 )
 
 const (
@@ -49,6 +89,9 @@ const (
 	ModeFocus
 	ModeMouseX10
 	ModeMouseMany
+	ModeMouseUtf8
+	ModeMouseUrxvt
+	ModeBracketedPaste
 	ModeMouseMask = ModeMouseButton | ModeMouseMotion | ModeMouseX10 | ModeMouseMany
 )
 
@@ -67,16 +110,58 @@ const (
 	Newline
 	ScrollUp
 	ScrollDown
+	ChangedPalette
+	ChangedCursorStyle
+	ChangedFocus
+	ChangedSelection
 )
 
 type Glyph struct {
 	Char   rune
-	Mode   int16
+	Comb   string // combining marks and joined code points following Char
+	Mode   int32
 	Fg, Bg Color
+	Ul     Color // underline color
+}
+
+// Attrs returns the character attributes of the glyph.
+func (g Glyph) Attrs() Attr {
+	return Attr(g.Mode) & attrSGR
+}
+
+// String returns the grapheme cluster displayed by the glyph. It is empty
+// for the placeholder cell following a double width character.
+func (g Glyph) String() string {
+	if g.Mode&attrWDummy != 0 {
+		return ""
+	}
+	return string(g.Char) + g.Comb
+}
+
+// Width returns the number of columns the glyph occupies: 2 for a double
+// width character, 0 for the placeholder cell that follows one, and 1
+// otherwise.
+func (g Glyph) Width() int {
+	switch {
+	case g.Mode&attrWide != 0:
+		return 2
+	case g.Mode&attrWDummy != 0:
+		return 0
+	}
+	return 1
 }
 
 type Line []Glyph
 
+// String returns the text of the line, including trailing blanks.
+func (l Line) String() string {
+	var b strings.Builder
+	for _, g := range l {
+		b.WriteString(g.String())
+	}
+	return b.String()
+}
+
 type Cursor struct {
 	Attr  Glyph
 	Glyph Glyph
@@ -84,6 +169,16 @@ type Cursor struct {
 	state uint8
 }
 
+// CursorShape is the cursor shape requested with DECSCUSR.
+type CursorShape uint8
+
+// Cursor shapes
+const (
+	CursorBlock CursorShape = iota
+	CursorUnderline
+	CursorBar
+)
+
 type parseState func(c rune)
 
 // State represents the terminal emulation state. Use Lock/Unlock
@@ -91,6 +186,14 @@ type parseState func(c rune)
 type State struct {
 	DebugLogger *log.Logger
 
+	// MaxHistory is the number of lines scrolled off the top of the
+	// primary screen that are kept as scrollback. Zero disables it.
+	MaxHistory int
+
+	// WordDelimiters are the characters separating words for SelectWord.
+	// DefaultWordDelimiters is used if it is empty.
+	WordDelimiters string
+
 	mu            sync.Mutex
 	changed       ChangeFlag
 	cols, rows    int
@@ -99,14 +202,23 @@ type State struct {
 	Dirty         []bool // line dirtiness
 	anydirty      bool
 	Cur, curSaved Cursor
-	top, bottom   int // scroll limits
+	curPrimary    Cursor // of the primary screen while the alt one is shown
+	top, bottom   int    // scroll limits
 	mode          ModeFlag
 	state         parseState
+	esc           bool // inside an escape sequence
 	str           strEscape
 	csi           csiEscape
 	numlock       bool
 	tabs          []bool
 	title         string
+	history       history
+	out           io.Writer // destination of terminal responses
+	palette       palette
+	curShape      CursorShape
+	curSteady     bool
+	unfocused     bool
+	sel           selection
 }
 
 func (t *State) logf(format string, args ...interface{}) {
@@ -121,6 +233,17 @@ func (t *State) logln(s string) {
 	}
 }
 
+// reply writes a terminal response, such as a device attributes or cursor
+// position report, back to the application.
+func (t *State) reply(format string, args ...interface{}) {
+	if t.out == nil {
+		return
+	}
+	if _, err := fmt.Fprintf(t.out, format, args...); err != nil {
+		t.logf("reply failed: %v\n", err)
+	}
+}
+
 func (t *State) lock() {
 	t.mu.Lock()
 }
@@ -164,6 +287,23 @@ func (t *State) CursorVisible() bool {
 	return t.mode&ModeHide == 0
 }
 
+// CursorStyle returns the shape of the Cursor and whether it blinks.
+func (t *State) CursorStyle() (shape CursorShape, blink bool) {
+	return t.curShape, !t.curSteady
+}
+
+func (t *State) setCursorStyle(shape CursorShape, blink bool) {
+	t.changed |= ChangedCursorStyle
+	t.curShape = shape
+	t.curSteady = !blink
+}
+
+// Focused returns whether the terminal window has focus, as reported with
+// VT.SetFocused. Renderers typically draw a hollow cursor when it does not.
+func (t *State) Focused() bool {
+	return !t.unfocused
+}
+
 // Mode tests if Mode is currently set.
 func (t *State) Mode(mode ModeFlag) bool {
 	return t.mode&mode != 0
@@ -265,6 +405,16 @@ func (t *State) setChar(c rune, attr *Glyph, x, y int) {
 	}
 	t.changed |= NewChar
 	t.Dirty[y] = true
+	// overwriting half of a wide character clears the other half
+	if t.lines[y][x].Mode&attrWide != 0 {
+		if x+1 < t.cols {
+			t.lines[y][x+1].Char = ' '
+			t.lines[y][x+1].Mode &^= attrWDummy
+		}
+	} else if t.lines[y][x].Mode&attrWDummy != 0 && x > 0 {
+		t.lines[y][x-1].Char = ' '
+		t.lines[y][x-1].Mode &^= attrWide
+	}
 	t.lines[y][x] = *attr
 	t.lines[y][x].Char = c
 	//if t.options.BrightBold && Attr.Mode&attrBold != 0 && Attr.Fg < 8 {
@@ -281,6 +431,7 @@ func (t *State) defaultCursor() Cursor {
 	c := Cursor{}
 	c.Attr.Fg = DefaultFG
 	c.Attr.Bg = DefaultBG
+	c.Attr.Ul = DefaultUL
 	return c
 }
 
@@ -295,8 +446,19 @@ func (t *State) reset() {
 	}
 	t.top = 0
 	t.bottom = t.rows - 1
+	if t.mode&ModeAltScreen != 0 {
+		t.swapScreen()
+	}
 	t.mode = ModeWrap
-	t.clear(0, 0, t.rows-1, t.cols-1)
+	t.history.reset()
+	t.ClearSelection()
+	t.palette.reset()
+	t.changed |= ChangedPalette
+	t.setCursorStyle(CursorBlock, true)
+	for i := 0; i < 2; i++ {
+		t.clearAll()
+		t.swapScreen()
+	}
 	t.moveTo(0, 0)
 }
 
@@ -308,6 +470,34 @@ func (t *State) resize(cols, rows int) bool {
 	if cols < 1 || rows < 1 {
 		return false
 	}
+	t.sel.active = false
+	// the primary screen is reflowed, the alt screen is only cut or padded
+	var reflowed []Line
+	if t.cols > 0 {
+		cur := &t.Cur
+		if t.mode&ModeAltScreen != 0 {
+			cur = &t.curPrimary
+		}
+		// the cursor saved by 1049 stays on the same cell
+		follow := t.curSaved == *cur
+		// a pending wrap anchors on the position after the last character,
+		// and is pending again if that is past the last column
+		x, wrap := cur.x, cur.state&cursorWrapNext != 0
+		if wrap {
+			x++
+		}
+		reflowed, cur.x, cur.y = t.reflow(cols, rows, x, cur.y)
+		cur.state &^= cursorWrapNext
+		if cur.x >= cols {
+			cur.x = cols - 1
+			if wrap {
+				cur.state |= cursorWrapNext
+			}
+		}
+		if follow {
+			t.curSaved = *cur
+		}
+	}
 	slide := t.Cur.y - rows + 1
 	if slide > 0 {
 		copy(t.lines, t.lines[slide:slide+rows])
@@ -338,16 +528,23 @@ func (t *State) resize(cols, rows int) bool {
 		for i > 0 && !tabs[i] {
 			i--
 		}
-		for i += tabspaces; i < len(tabs); i += tabspaces {
-			tabs[i] = true
+		for i += tabspaces; i < cols; i += tabspaces {
+			t.tabs[i] = true
 		}
 	}
 
 	t.cols = cols
 	t.rows = rows
 	t.setScroll(0, rows-1)
+	wrapNext := t.Cur.state & cursorWrapNext
 	t.moveTo(t.Cur.x, t.Cur.y)
+	if reflowed != nil && t.mode&ModeAltScreen == 0 {
+		t.Cur.state |= wrapNext
+	}
 	for i := 0; i < 2; i++ {
+		for y := 0; y < minrows; y++ {
+			t.fixWide(cols, y)
+		}
 		if mincols < cols && minrows > 0 {
 			t.clear(mincols, 0, cols-1, minrows-1)
 		}
@@ -356,6 +553,13 @@ func (t *State) resize(cols, rows int) bool {
 		}
 		t.swapScreen()
 	}
+	if reflowed != nil {
+		if t.mode&ModeAltScreen != 0 {
+			copy(t.altLines, reflowed)
+		} else {
+			copy(t.lines, reflowed)
+		}
+	}
 	return slide > 0
 }
 
@@ -374,9 +578,30 @@ func (t *State) clear(x0, y0, x1, y1 int) {
 	for y := y0; y <= y1; y++ {
 		t.Dirty[y] = true
 		for x := x0; x <= x1; x++ {
+			if t.IsSelected(x, y) {
+				t.ClearSelection()
+			}
 			t.lines[y][x] = t.Cur.Attr
 			t.lines[y][x].Char = ' '
 		}
+		t.fixWide(x0, y)
+		t.fixWide(x1+1, y)
+	}
+}
+
+// fixWide blanks what remains of a double width character split at the
+// boundary between columns x-1 and x of row y.
+func (t *State) fixWide(x, y int) {
+	line := t.lines[y]
+	if x > 0 && x <= t.cols && line[x-1].Mode&attrWide != 0 &&
+		(x == t.cols || line[x].Mode&attrWDummy == 0) {
+		line[x-1].Char = ' '
+		line[x-1].Mode &^= attrWide
+	}
+	if x < t.cols && line[x].Mode&attrWDummy != 0 &&
+		(x == 0 || line[x-1].Mode&attrWide == 0) {
+		line[x].Char = ' '
+		line[x].Mode &^= attrWDummy
 	}
 }
 
@@ -472,11 +697,14 @@ func (t *State) ScrollDown(orig, n int) {
 		t.Dirty[i-n] = true
 	}
 
-	// TODO: selection scroll
+	t.scrollSelection(orig, n)
 }
 
 func (t *State) ScrollUp(orig, n int) {
 	n = clamp(n, 0, t.bottom-orig+1)
+	if orig == 0 && t.mode&ModeAltScreen == 0 {
+		t.pushHistory(t.lines[:n]...)
+	}
 	t.clear(0, orig, t.cols-1, orig+n-1)
 	t.changed |= ScrollUp
 	for i := orig; i <= t.bottom-n; i++ {
@@ -485,7 +713,7 @@ func (t *State) ScrollUp(orig, n int) {
 		t.Dirty[i+n] = true
 	}
 
-	// TODO: selection scroll
+	t.scrollSelection(orig, -n)
 }
 
 func (t *State) modMode(set bool, bit ModeFlag) {
@@ -555,6 +783,9 @@ func (t *State) setMode(priv bool, set bool, args []int) {
 					t.clear(0, 0, t.cols-1, t.rows-1)
 				}
 				if !set || !alt {
+					if !alt {
+						t.curPrimary = t.Cur
+					}
 					t.swapScreen()
 				}
 				if a != 1049 {
@@ -573,11 +804,13 @@ func (t *State) setMode(priv bool, set bool, args []int) {
 			case 1005:
 				// utf8 mouse Mode; will confuse applications not supporting
 				// utf8 and luit
+				t.modMode(set, ModeMouseUtf8)
 			case 1015:
 				// urxvt mangled mouse Mode; incompatiblt and can be mistaken
 				// for other control codes
-			case 2004:
-				//Ignores vim bracketed mode
+				t.modMode(set, ModeMouseUrxvt)
+			case 2004: // bracketed paste
+				t.modMode(set, ModeBracketedPaste)
 			default:
 				t.logf("unknown private set/reset Mode %d\n", a)
 			}
@@ -590,7 +823,6 @@ func (t *State) setMode(priv bool, set bool, args []int) {
 				t.modMode(set, ModeKeyboardLock)
 			case 4: // IRM - insertion-replacement
 				t.modMode(set, ModeInsert)
-				t.logln("insert Mode not implemented")
 			case 12: // SRM - send/receive
 				t.modMode(set, ModeEcho)
 			case 20: // LNM - linefeed/newline
@@ -606,63 +838,76 @@ func (t *State) setMode(priv bool, set bool, args []int) {
 	}
 }
 
-func (t *State) setAttr(attr []int) {
+func (t *State) setAttr(attr [][]int) {
 	if len(attr) == 0 {
-		attr = []int{0}
+		attr = [][]int{{0}}
 	}
 	for i := 0; i < len(attr); i++ {
-		a := attr[i]
+		a := attr[i][0]
 		switch a {
 		case 0:
-			t.Cur.Attr.Mode &^= attrReverse | attrUnderline | attrBold | attrItalic | attrBlink
+			t.Cur.Attr.Mode &^= int32(attrSGR)
 			t.Cur.Attr.Fg = DefaultFG
 			t.Cur.Attr.Bg = DefaultBG
+			t.Cur.Attr.Ul = DefaultUL
 		case 1:
 			t.Cur.Attr.Mode |= attrBold
+		case 2:
+			t.Cur.Attr.Mode |= attrFaint
 		case 3:
 			t.Cur.Attr.Mode |= attrItalic
 		case 4:
-			t.Cur.Attr.Mode |= attrUnderline
+			style := 1
+			if len(attr[i]) > 1 {
+				style = attr[i][1]
+			}
+			t.setUnderline(style)
 		case 5, 6: // slow, rapid blink
 			t.Cur.Attr.Mode |= attrBlink
 		case 7:
 			t.Cur.Attr.Mode |= attrReverse
-		case 21, 22:
-			t.Cur.Attr.Mode &^= attrBold
+		case 8:
+			t.Cur.Attr.Mode |= attrInvisible
+		case 9:
+			t.Cur.Attr.Mode |= attrStrike
+		case 21:
+			t.setUnderline(2)
+		case 22:
+			t.Cur.Attr.Mode &^= attrBold | attrFaint
 		case 23:
 			t.Cur.Attr.Mode &^= attrItalic
 		case 24:
-			t.Cur.Attr.Mode &^= attrUnderline
+			t.setUnderline(0)
 		case 25, 26:
 			t.Cur.Attr.Mode &^= attrBlink
 		case 27:
 			t.Cur.Attr.Mode &^= attrReverse
+		case 28:
+			t.Cur.Attr.Mode &^= attrInvisible
+		case 29:
+			t.Cur.Attr.Mode &^= attrStrike
 		case 38:
-			if i+2 < len(attr) && attr[i+1] == 5 {
-				i += 2
-				if between(attr[i], 0, 255) {
-					t.Cur.Attr.Fg = Color(attr[i])
-				} else {
-					t.logf("bad fgcolor %d\n", attr[i])
-				}
-			} else {
-				t.logf("gfx Attr %d unknown\n", a)
+			if c, ok := t.parseColor(attr, &i); ok {
+				t.Cur.Attr.Fg = c
 			}
 		case 39:
 			t.Cur.Attr.Fg = DefaultFG
 		case 48:
-			if i+2 < len(attr) && attr[i+1] == 5 {
-				i += 2
-				if between(attr[i], 0, 255) {
-					t.Cur.Attr.Bg = Color(attr[i])
-				} else {
-					t.logf("bad bgcolor %d\n", attr[i])
-				}
-			} else {
-				t.logf("gfx Attr %d unknown\n", a)
+			if c, ok := t.parseColor(attr, &i); ok {
+				t.Cur.Attr.Bg = c
 			}
 		case 49:
 			t.Cur.Attr.Bg = DefaultBG
+		case 53:
+			t.Cur.Attr.Mode |= attrOverline
+		case 55:
+			t.Cur.Attr.Mode &^= attrOverline
+		case 58:
+			if c, ok := t.parseColor(attr, &i); ok {
+				t.Cur.Attr.Ul = c
+			}
+		case 59:
+			t.Cur.Attr.Ul = DefaultUL
 		default:
 			if between(a, 30, 37) {
 				t.Cur.Attr.Fg = Color(a - 30)
@@ -679,6 +924,73 @@ func (t *State) setAttr(attr []int) {
 	}
 }
 
+// setUnderline sets the underline style from the SGR 4:n sub-parameter.
+func (t *State) setUnderline(style int) {
+	var bit int32
+	switch style {
+	case 0: // none
+	case 1:
+		bit = attrUnderline
+	case 2:
+		bit = attrDoubleUnderline
+	case 3:
+		bit = attrCurlyUnderline
+	case 4:
+		bit = attrDottedUnderline
+	case 5:
+		bit = attrDashedUnderline
+	default:
+		t.logf("unknown underline style %d\n", style)
+		return
+	}
+	t.Cur.Attr.Mode &^= int32(attrUnderlines)
+	t.Cur.Attr.Mode |= bit
+}
+
+// parseColor parses the extended color following attr[*i], either 5;n for
+// the 256 color palette or 2;r;g;b for direct color. The colon separated
+// forms 5:n, 2:r:g:b and 2:cs:r:g:b are accepted as sub-parameters.
+// *i is advanced past the consumed arguments.
+func (t *State) parseColor(attr [][]int, i *int) (Color, bool) {
+	if sub := attr[*i][1:]; len(sub) > 0 {
+		if len(sub) > 4 && sub[0] == 2 {
+			// skip the color space id
+			sub = append([]int{2}, sub[2:]...)
+		}
+		c, _, ok := t.extColor(attr[*i][0], sub)
+		return c, ok
+	}
+	var args []int
+	for _, p := range attr[*i+1:] {
+		args = append(args, p[0])
+	}
+	c, n, ok := t.extColor(attr[*i][0], args)
+	*i += n
+	return c, ok
+}
+
+// extColor returns the color described by args and the number of args
+// used.
+func (t *State) extColor(a int, args []int) (Color, int, bool) {
+	switch {
+	case len(args) >= 2 && args[0] == 5:
+		if !between(args[1], 0, 255) {
+			t.logf("bad color %d\n", args[1])
+			return 0, 2, false
+		}
+		return Color(args[1]), 2, true
+	case len(args) >= 4 && args[0] == 2:
+		r, g, b := args[1], args[2], args[3]
+		if !between(r, 0, 255) || !between(g, 0, 255) || !between(b, 0, 255) {
+			t.logf("bad rgb color %d;%d;%d\n", r, g, b)
+			return 0, 4, false
+		}
+		return RGB(uint8(r), uint8(g), uint8(b)), 4, true
+	}
+	t.logf("gfx Attr %d unknown\n", a)
+	return 0, 0, false
+}
+
 func (t *State) insertBlanks(n int) {
 	src := t.Cur.x
 	dst := src + n
@@ -691,6 +1003,7 @@ func (t *State) insertBlanks(n int) {
 	} else {
 		copy(t.lines[t.Cur.y][dst:dst+size], t.lines[t.Cur.y][src:src+size])
 		t.clear(src, t.Cur.y, dst-1, t.Cur.y)
+		t.fixWide(t.cols, t.Cur.y)
 	}
 }
 
@@ -720,6 +1033,7 @@ func (t *State) deleteChars(n int) {
 	} else {
 		copy(t.lines[t.Cur.y][dst:dst+size], t.lines[t.Cur.y][src:src+size])
 		t.clear(t.cols-n, t.Cur.y, t.cols-1, t.Cur.y)
+		t.fixWide(dst, t.Cur.y)
 	}
 }
 
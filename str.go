@@ -12,12 +12,14 @@ type strEscape struct {
 	typ  rune
 	buf  []rune
 	args []string
+	bel  bool // terminated by BEL rather than ST
 }
 
 func (s *strEscape) reset() {
 	s.typ = 0
 	s.buf = s.buf[:0]
 	s.args = nil
+	s.bel = false
 }
 
 func (s *strEscape) put(c rune) {
@@ -53,6 +55,15 @@ func (s *strEscape) argString(i int, def string) string {
 	return s.args[i]
 }
 
+// oscReply writes an OSC response with the terminator of the request.
+func (t *State) oscReply(format string, args ...interface{}) {
+	end := "\033\\"
+	if t.str.bel {
+		end = "\a"
+	}
+	t.reply("\033]"+format+end, args...)
+}
+
 func (t *State) handleSTR() {
 	s := &t.str
 	s.parse()
@@ -65,19 +76,30 @@ func (t *State) handleSTR() {
 			if title != "" {
 				t.setTitle(title)
 			}
-		case 4: // color set
+		case 4: // color set: 4;index;spec[;index;spec...]
 			if len(s.args) < 3 {
 				break
 			}
-			// setcolorname(s.arg(1, 0), s.argString(2, ""))
-		case 104: // color reset
-			// TODO: complain about invalid color, redraw, etc.
-			// setcolorname(s.arg(1, 0), nil)
-
-		case 11, 112:
-			// ignoring:
-			// 11: Change background color
-			// 112: Change cursor color
+			for i := 1; i+1 < len(s.args); i += 2 {
+				t.oscColor(4, s.arg(i, -1), s.args[i+1])
+			}
+		case 10, 11, 12: // foreground, background and cursor color
+			// additional specs set the following colors
+			for i := 1; i < len(s.args) && d+i-1 <= 12; i++ {
+				t.oscColor(d+i-1, 0, s.args[i])
+			}
+		case 104: // color reset: 104[;index...]
+			if len(s.args) == 1 || s.argString(1, "") == "" {
+				for i := 0; i < 256; i++ {
+					t.oscResetColor(4, i)
+				}
+				break
+			}
+			for i := 1; i < len(s.args); i++ {
+				t.oscResetColor(4, s.arg(i, -1))
+			}
+		case 110, 111, 112: // foreground, background and cursor color reset
+			t.oscResetColor(d-100, 0)
 		default:
 			t.logf("unknown OSC command %d\n", d)
 			// TODO: s.dump()
@@ -93,7 +115,7 @@ func (t *State) handleSTR() {
 		// '_': // APC - application program command
 		// '^': // PM - privacy message
 
-		t.logf("unhandled STR sequence '%Char'\n", s.typ)
+		t.logf("unhandled STR sequence '%c'\n", s.typ)
 		// t.str.dump()
 	}
 }
@@ -0,0 +1,262 @@
+package vt10x
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strings"
+)
+
+// SVGOptions control the output of WriteSVG.
+type SVGOptions struct {
+	// Theme maps colors to RGB. The current colors of the terminal are
+	// used if it is nil.
+	Theme *Theme
+	// FontFamily is the font of the text, monospace if empty.
+	FontFamily string
+	// FontSize is the font size in pixels, 14 if zero. Cells are 0.6 by
+	// 1.2 times the font size.
+	FontSize float64
+	// Padding is the space around the grid in pixels.
+	Padding float64
+}
+
+// arms of the box drawing characters drawn as lines from the cell center
+const (
+	boxUp = 1 << iota
+	boxDown
+	boxLeft
+	boxRight
+)
+
+var boxChars = map[rune]uint8{
+	'─': boxLeft | boxRight,
+	'│': boxUp | boxDown,
+	'┌': boxDown | boxRight,
+	'┐': boxDown | boxLeft,
+	'└': boxUp | boxRight,
+	'┘': boxUp | boxLeft,
+	'├': boxUp | boxDown | boxRight,
+	'┤': boxUp | boxDown | boxLeft,
+	'┬': boxDown | boxLeft | boxRight,
+	'┴': boxUp | boxLeft | boxRight,
+	'┼': boxUp | boxDown | boxLeft | boxRight,
+}
+
+// height of the scan line characters in the cell
+var scanLines = map[rune]float64{
+	'⎺': 0.1,
+	'⎻': 0.3,
+	'⎼': 0.7,
+	'⎽': 0.9,
+}
+
+// opacity of the shade characters filling the cell
+var shadeChars = map[rune]float64{
+	'█': 1,
+	'▒': 0.5,
+}
+
+// WriteSVG writes the screen as an SVG image on a monospace grid. The
+// line drawing characters of the DEC graphics set are drawn as vectors so
+// that they join across cells, and the cursor is drawn in its current
+// style when visible.
+func (t *State) WriteSVG(w io.Writer, opts SVGOptions) error {
+	th := t.Theme()
+	if opts.Theme != nil {
+		th = *opts.Theme
+	}
+	if t.mode&ModeReverse != 0 {
+		th.Foreground, th.Background = th.Background, th.Foreground
+	}
+	font := opts.FontFamily
+	if font == "" {
+		font = "monospace"
+	}
+	size := opts.FontSize
+	if size <= 0 {
+		size = 14
+	}
+	pad := opts.Padding
+	cw, ch := size*0.6, size*1.2
+	cellX := func(x int) string { return svgNum(pad + float64(x)*cw) }
+	cellY := func(y int) string { return svgNum(pad + float64(y)*ch) }
+
+	b := bufio.NewWriter(w)
+	width, height := 2*pad+float64(t.cols)*cw, 2*pad+float64(t.rows)*ch
+	fmt.Fprintf(b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%s\" height=\"%s\" viewBox=\"0 0 %[1]s %[2]s\">\n",
+		svgNum(width), svgNum(height))
+	fmt.Fprintf(b, "<rect width=\"100%%\" height=\"100%%\" fill=\"%s\"/>\n", cssColor(th.Background))
+
+	// backgrounds, merged into runs of the same color
+	for y := 0; y < t.rows; y++ {
+		for x := 0; x < t.cols; {
+			bg := t.lines[y][x].Bg
+			n := 1
+			for x+n < t.cols && t.lines[y][x+n].Bg == bg {
+				n++
+			}
+			if bg != DefaultBG {
+				fmt.Fprintf(b, "<rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" fill=\"%s\"/>\n",
+					cellX(x), cellY(y), svgNum(float64(n)*cw), svgNum(ch), cssColor(th.Resolve(bg)))
+			}
+			x += n
+		}
+	}
+
+	// text, one element per run of cells with the same style, placing
+	// every character in its cell
+	fmt.Fprintf(b, "<g font-family=\"%s\" font-size=\"%s\" fill=\"%s\">\n",
+		html.EscapeString(font), svgNum(size), cssColor(th.Foreground))
+	for y := 0; y < t.rows; y++ {
+		var text strings.Builder
+		var xs []string
+		style := ""
+		flush := func() {
+			if len(xs) > 0 {
+				fmt.Fprintf(b, "<text x=\"%s\" y=\"%s\"%s>%s</text>\n", strings.Join(xs, " "),
+					svgNum(pad+float64(y)*ch+0.9*size), style, text.String())
+			}
+			text.Reset()
+			xs = xs[:0]
+		}
+		for x := 0; x < t.cols; x++ {
+			g := t.lines[y][x]
+			if g.Mode&attrWDummy != 0 || g.Char == ' ' && g.Comb == "" ||
+				g.Mode&attrInvisible != 0 || isDrawnChar(g.Char) {
+				continue
+			}
+			if s := svgStyle(g, &th); s != style {
+				flush()
+				style = s
+			}
+			text.WriteString(html.EscapeString(g.String()))
+			for range g.String() {
+				xs = append(xs, cellX(x))
+			}
+		}
+		flush()
+	}
+	b.WriteString("</g>\n")
+
+	// line drawing and shade characters
+	for y := 0; y < t.rows; y++ {
+		for x := 0; x < t.cols; x++ {
+			g := t.lines[y][x]
+			if g.Mode&attrInvisible != 0 || !isDrawnChar(g.Char) {
+				continue
+			}
+			x0, y0 := pad+float64(x)*cw, pad+float64(y)*ch
+			color := cssColor(th.Resolve(g.Fg))
+			if arms, ok := boxChars[g.Char]; ok {
+				cx, cy := x0+cw/2, y0+ch/2
+				var d strings.Builder
+				for _, arm := range []struct {
+					bit    uint8
+					dx, dy float64
+				}{{boxUp, 0, -ch / 2}, {boxDown, 0, ch / 2}, {boxLeft, -cw / 2, 0}, {boxRight, cw / 2, 0}} {
+					if arms&arm.bit != 0 {
+						fmt.Fprintf(&d, "M%s %sL%s %s", svgNum(cx), svgNum(cy), svgNum(cx+arm.dx), svgNum(cy+arm.dy))
+					}
+				}
+				fmt.Fprintf(b, "<path d=\"%s\" stroke=\"%s\" stroke-width=\"%s\" stroke-linecap=\"square\"/>\n",
+					d.String(), color, svgNum(size/14))
+			} else if h, ok := scanLines[g.Char]; ok {
+				fmt.Fprintf(b, "<path d=\"M%s %sh%s\" stroke=\"%s\" stroke-width=\"%s\"/>\n",
+					svgNum(x0), svgNum(y0+h*ch), svgNum(cw), color, svgNum(size/14))
+			} else {
+				fmt.Fprintf(b, "<rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" fill=\"%s\" opacity=\"%s\"/>\n",
+					svgNum(x0), svgNum(y0), svgNum(cw), svgNum(ch), color, svgNum(shadeChars[g.Char]))
+			}
+		}
+	}
+
+	if t.CursorVisible() {
+		t.writeSVGCursor(b, &th, font, pad, size)
+	}
+	b.WriteString("</svg>\n")
+	return b.Flush()
+}
+
+// writeSVGCursor draws the cursor in its shape, as a hollow block when the
+// terminal is not focused. A block cursor redraws the character under it
+// in the background color.
+func (t *State) writeSVGCursor(b *bufio.Writer, th *Theme, font string, pad, size float64) {
+	cw, ch := size*0.6, size*1.2
+	x := t.Cur.x
+	if t.lines[t.Cur.y][x].Mode&attrWDummy != 0 && x > 0 {
+		x--
+	}
+	g := t.lines[t.Cur.y][x]
+	x0, y0 := pad+float64(x)*cw, pad+float64(t.Cur.y)*ch
+	w := cw * float64(g.Width())
+	color := cssColor(th.Cursor)
+	shape, _ := t.CursorStyle()
+	switch {
+	case !t.Focused():
+		fmt.Fprintf(b, "<rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" fill=\"none\" stroke=\"%s\"/>\n",
+			svgNum(x0+0.5), svgNum(y0+0.5), svgNum(w-1), svgNum(ch-1), color)
+	case shape == CursorUnderline:
+		fmt.Fprintf(b, "<rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" fill=\"%s\"/>\n",
+			svgNum(x0), svgNum(y0+ch-size/7), svgNum(w), svgNum(size/7), color)
+	case shape == CursorBar:
+		fmt.Fprintf(b, "<rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" fill=\"%s\"/>\n",
+			svgNum(x0), svgNum(y0), svgNum(size/7), svgNum(ch), color)
+	default:
+		fmt.Fprintf(b, "<rect x=\"%s\" y=\"%s\" width=\"%s\" height=\"%s\" fill=\"%s\"/>\n",
+			svgNum(x0), svgNum(y0), svgNum(w), svgNum(ch), color)
+		if s := g.String(); strings.TrimSpace(s) != "" && !isDrawnChar(g.Char) {
+			fmt.Fprintf(b, "<text x=\"%s\" y=\"%s\" font-family=\"%s\" font-size=\"%s\" fill=\"%s\">%s</text>\n",
+				svgNum(x0), svgNum(y0+0.9*size), html.EscapeString(font), svgNum(size),
+				cssColor(th.Resolve(g.Bg)), html.EscapeString(s))
+		}
+	}
+}
+
+// isDrawnChar returns true if c is drawn as a vector instead of text.
+func isDrawnChar(c rune) bool {
+	_, box := boxChars[c]
+	_, scan := scanLines[c]
+	_, shade := shadeChars[c]
+	return box || scan || shade
+}
+
+// svgStyle returns the presentation attributes of the text of g, or an
+// empty string for a plain cell.
+func svgStyle(g Glyph, th *Theme) string {
+	var s strings.Builder
+	attrs := g.Attrs()
+	if g.Fg != DefaultFG {
+		fmt.Fprintf(&s, " fill=\"%s\"", cssColor(th.Resolve(g.Fg)))
+	}
+	if attrs&AttrBold != 0 {
+		s.WriteString(" font-weight=\"bold\"")
+	}
+	if attrs&AttrItalic != 0 {
+		s.WriteString(" font-style=\"italic\"")
+	}
+	if attrs&AttrFaint != 0 {
+		s.WriteString(" opacity=\"0.5\"")
+	}
+	var lines []string
+	if attrs&attrUnderlines != 0 {
+		lines = append(lines, "underline")
+	}
+	if attrs&AttrStrike != 0 {
+		lines = append(lines, "line-through")
+	}
+	if attrs&AttrOverline != 0 {
+		lines = append(lines, "overline")
+	}
+	if lines != nil {
+		fmt.Fprintf(&s, " text-decoration=\"%s\"", strings.Join(lines, " "))
+	}
+	return s.String()
+}
+
+// svgNum formats a coordinate with at most two decimals.
+func svgNum(v float64) string {
+	return fmt.Sprint(math.Round(v*100) / 100)
+}
@@ -0,0 +1,47 @@
+package vt10x
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWriteSVG(t *testing.T) {
+	var st State
+	term, err := Create(&st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	term.Resize(10, 2)
+	term.Write([]byte("\033[41ma<\033[0m世\r\n\033(0lqk\033(B\033[4 q"))
+
+	var b strings.Builder
+	if err := st.WriteSVG(&b, SVGOptions{FontSize: 10, Padding: 2}); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, expected := range []string{
+		`width="64" height="28" viewBox="0 0 64 28"`,
+		`<rect x="2" y="2" width="12" height="12" fill="#cd0000"/>`,
+		`<text x="2 8 14" y="11">a&lt;世</text>`,
+		`<path d="M5 20L5 26M5 20L8 20"`,
+		`<path d="M11 20L8 20M11 20L14 20"`,
+		// underline cursor after the box drawing characters
+		`<rect x="20" y="24.57" width="6" height="1.43" fill="#e5e5e5"/>`,
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("missing %q in\n%s", expected, out)
+		}
+	}
+
+	// the output is well formed
+	d := xml.NewDecoder(strings.NewReader(out))
+	for {
+		if _, err := d.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("invalid SVG: %v\n%s", err, out)
+		}
+	}
+}
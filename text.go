@@ -0,0 +1,65 @@
+package vt10x
+
+import (
+	"strings"
+)
+
+// TextOptions control text extraction with LogicalLines.
+type TextOptions struct {
+	// Scrollback includes the history above the primary screen. It is
+	// ignored while the alt screen is shown.
+	Scrollback bool
+	// TrimTrailing removes trailing blanks from each line.
+	TrimTrailing bool
+}
+
+// LineText returns the text of row y of the screen, without trailing
+// blanks.
+func (t *State) LineText(y int) string {
+	return strings.TrimRight(t.lines[y].String(), " ")
+}
+
+// String returns the text of the screen, one row per line, without
+// trailing blanks.
+func (t *State) String() string {
+	rows := make([]string, t.rows)
+	for y := range rows {
+		rows[y] = t.LineText(y)
+	}
+	return strings.Join(rows, "\n")
+}
+
+// LogicalLines calls fn with the text of each line, top to bottom, until
+// it returns false. Rows continued by a soft wrap are joined into a single
+// line.
+func (t *State) LogicalLines(opts TextOptions, fn func(line string) bool) {
+	var b strings.Builder
+	more := true
+	emit := func(l Line) {
+		b.WriteString(l.String())
+		if len(l) > 0 && l[len(l)-1].Mode&attrWrap != 0 {
+			return
+		}
+		s := b.String()
+		b.Reset()
+		if opts.TrimTrailing {
+			s = strings.TrimRight(s, " ")
+		}
+		more = fn(s)
+	}
+	if opts.Scrollback && t.mode&ModeAltScreen == 0 {
+		for i := 0; i < t.HistoryLen() && more; i++ {
+			emit(t.history.at(i))
+		}
+	}
+	for y := 0; y < t.rows && more; y++ {
+		emit(t.lines[y])
+	}
+	if more && b.Len() > 0 {
+		s := b.String()
+		if opts.TrimTrailing {
+			s = strings.TrimRight(s, " ")
+		}
+		fn(s)
+	}
+}
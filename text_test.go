@@ -0,0 +1,51 @@
+package vt10x
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	st := State{MaxHistory: 10}
+	term, err := Create(&st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	term.Resize(6, 3)
+	term.Write([]byte("one  \r\nabcdefgh\r\n世界!"))
+
+	if s := st.String(); s != "abcdef\ngh\n世界!" {
+		t.Fatalf("unexpected screen %q", s)
+	}
+	if s := st.LineText(2); s != "世界!" {
+		t.Fatalf("unexpected line %q", s)
+	}
+
+	tests := []struct {
+		opts     TextOptions
+		expected []string
+	}{
+		{TextOptions{}, []string{"abcdefgh    ", "世界! "}},
+		{TextOptions{TrimTrailing: true}, []string{"abcdefgh", "世界!"}},
+		{TextOptions{Scrollback: true, TrimTrailing: true}, []string{"one", "abcdefgh", "世界!"}},
+	}
+	for _, test := range tests {
+		var lines []string
+		st.LogicalLines(test.opts, func(line string) bool {
+			lines = append(lines, line)
+			return true
+		})
+		if fmt.Sprintf("%q", lines) != fmt.Sprintf("%q", test.expected) {
+			t.Fatalf("%+v: got %q, expected %q", test.opts, lines, test.expected)
+		}
+	}
+
+	var n int
+	st.LogicalLines(TextOptions{Scrollback: true}, func(string) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Fatalf("iteration did not stop, got %d lines", n)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"sync"
 	"unicode"
 	"unicode/utf8"
 
@@ -21,6 +22,9 @@ type VT struct {
 	rc   io.ReadCloser
 	br   *bufio.Reader
 	pty  *os.File
+
+	recMu sync.Mutex
+	rec   *Recorder
 }
 
 // Start initializes a virtual terminal emulator with the target state
@@ -52,15 +56,29 @@ func Create(state *State, rc io.ReadCloser) (*VT, error) {
 }
 
 func (t *VT) init() {
-	t.br = bufio.NewReader(t.rc)
+	t.br = bufio.NewReader(readFunc(t.read))
+	if w, ok := t.rc.(io.Writer); ok {
+		t.dest.out = w
+	}
 	t.dest.numlock = true
 	t.dest.state = t.dest.parse
 	t.dest.Cur.Attr.Fg = DefaultFG
 	t.dest.Cur.Attr.Bg = DefaultBG
+	t.dest.Cur.Attr.Ul = DefaultUL
 	t.Resize(80, 24)
 	t.dest.reset()
 }
 
+// SetOutput sets the writer receiving terminal responses, such as device
+// attributes and cursor position reports. It defaults to the pty when
+// started with Start, or to the io.ReadCloser given to Create if it is
+// also an io.Writer. A nil writer discards responses.
+func (t *VT) SetOutput(w io.Writer) {
+	t.dest.lock()
+	defer t.dest.unlock()
+	t.dest.out = w
+}
+
 // File returns the pty file.
 func (t *VT) File() *os.File {
 	return t.pty
@@ -84,6 +102,7 @@ func (t *VT) Write(p []byte) (int, error) {
 		if c == unicode.ReplacementChar && sz == 1 {
 			if r.Len() == 0 {
 				// not enough bytes for a full rune
+				t.recordOutput(p[:written-1])
 				return written - 1, nil
 			}
 			t.dest.logln("invalid utf8 sequence")
@@ -91,6 +110,7 @@ func (t *VT) Write(p []byte) (int, error) {
 		}
 		t.dest.put(c)
 	}
+	t.recordOutput(p)
 	return written, nil
 }
 
@@ -152,4 +172,5 @@ func (t *VT) Resize(cols, rows int) {
 	defer t.dest.unlock()
 	_ = t.dest.resize(cols, rows)
 	t.ptyResize()
+	t.recordResize(cols, rows)
 }
@@ -4,16 +4,9 @@ import (
 	"io"
 	"strings"
 	"testing"
-)
 
-func extractStr(t *State, x0, x1, row int) string {
-	var s []rune
-	for i := x0; i <= x1; i++ {
-		c, _, _ := t.Cell(i, row)
-		s = append(s, c)
-	}
-	return string(s)
-}
+	"github.com/mattn/go-runewidth"
+)
 
 func TestPlainChars(t *testing.T) {
 	var st State
@@ -26,7 +19,7 @@ func TestPlainChars(t *testing.T) {
 	if err != nil && err != io.EOF {
 		t.Fatal(err)
 	}
-	actual := extractStr(&st, 0, len(expected)-1, 0)
+	actual := st.LineText(0)
 	if expected != actual {
 		t.Fatal(actual)
 	}
@@ -48,10 +41,7 @@ func TestNewline(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	split := strings.Split(expected, "\n")
-	actual := extractStr(&st, 0, len(split[0])-1, 0)
-	actual += "\n"
-	actual += extractStr(&st, 0, len(split[1])-1, 1)
+	actual := st.LineText(0) + "\n" + st.LineText(1)
 	if expected != actual {
 		t.Fatal(actual)
 	}
@@ -68,3 +58,256 @@ func TestNewline(t *testing.T) {
 		t.Fatal(st.Cur.x, st.Cur.y, fg, bg)
 	}
 }
+
+func TestResponses(t *testing.T) {
+	var st State
+	term, err := Create(&st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	term.SetOutput(&out)
+
+	tests := []struct {
+		in, expected string
+	}{
+		{"\033[c", primaryDA},
+		{"\033[0c", primaryDA},
+		{"\033Z", primaryDA},
+		{"\033[>c", secondaryDA},
+		{"\033[=c", ""},
+		{"\033[<c", ""},
+		{"\033[5n", "\033[0n"},
+		{"\033[3;5H\033[6n", "\033[3;5R"},
+		{"\033[?6n", "\033[?3;5R"},
+		// origin mode reports relative to the scroll region
+		{"\033[2;10r\033[?6h\033[4;2H\033[6n", "\033[4;2R"},
+	}
+	for _, test := range tests {
+		out.Reset()
+		term.Write([]byte(test.in))
+		if out.String() != test.expected {
+			t.Fatalf("%q: got %q, expected %q", test.in, out.String(), test.expected)
+		}
+	}
+}
+
+func TestWideChars(t *testing.T) {
+	var st State
+	term, err := Create(&st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	term.Resize(5, 3)
+	term.Write([]byte("a世界"))
+	widths := []int{1, 2, 0, 2, 0}
+	for x, w := range widths {
+		if g := st.lines[0][x]; g.Width() != w {
+			t.Fatalf("cell %d has width %d, expected %d", x, g.Width(), w)
+		}
+	}
+
+	// a wide character in the last column wraps to the next line, erasing
+	// the skipped column
+	term.Write([]byte("\033[2;1HXXXXX\033[2;1Habcd世"))
+	if st.lines[1][4].Char != ' ' || st.lines[1][4].Mode&attrWrap == 0 {
+		t.Fatalf("expected wrapped blank in last column, got %q", st.lines[1][4].Char)
+	}
+	if st.lines[2][0].Char != '世' || st.lines[2][1].Width() != 0 {
+		t.Fatal("expected wide character at start of next line")
+	}
+
+	// deleting the first half of a wide character blanks the second
+	term.Write([]byte("\033[1;2H\033[P"))
+	if st.lines[0][1].Char != ' ' || st.lines[0][1].Width() != 1 {
+		t.Fatalf("expected blank, got %q width %d", st.lines[0][1].Char, st.lines[0][1].Width())
+	}
+	if st.lines[0][2].Char != '界' || st.lines[0][2].Width() != 2 {
+		t.Fatal("expected remaining wide character to shift left")
+	}
+
+	// erasing the placeholder half blanks the first half
+	term.Write([]byte("\033[1;4H\033[X"))
+	if st.lines[0][2].Char != ' ' || st.lines[0][2].Width() != 1 {
+		t.Fatalf("expected blank, got %q width %d", st.lines[0][2].Char, st.lines[0][2].Width())
+	}
+
+	// a single column holds wide characters as narrow ones
+	for _, wrap := range []string{"\033[?7h", "\033[?7l"} {
+		term.Resize(1, 2)
+		term.Write([]byte(wrap + "\033[H世界"))
+		if st.lines[0][0].Width() != 1 {
+			t.Fatalf("expected a narrow cell, got width %d", st.lines[0][0].Width())
+		}
+	}
+	term.Write([]byte("\033[?7h"))
+	term.Resize(5, 3)
+
+	// ambiguous widths do not follow the locale
+	defer func(ea bool) { runewidth.DefaultCondition.EastAsianWidth = ea }(runewidth.DefaultCondition.EastAsianWidth)
+	runewidth.DefaultCondition.EastAsianWidth = true
+	term.Write([]byte("\033[3;1H─│x"))
+	if st.lines[2][1].Char != '│' || st.lines[2][2].Char != 'x' {
+		t.Fatalf("expected ambiguous characters in one cell, got %q", st.LineText(2))
+	}
+}
+
+func TestCombiningChars(t *testing.T) {
+	var st State
+	term, err := Create(&st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	term.Resize(4, 2)
+	// e + combining acute, family emoji joined with ZWJ, heart + VS16
+	term.Write([]byte("e\u0301\U0001F468\u200d\U0001F469x\r\n\u2764\ufe0f"))
+	expected := []string{"e\u0301", "\U0001F468\u200d\U0001F469", "", "x"}
+	for x, s := range expected {
+		if g := st.lines[0][x]; g.String() != s {
+			t.Fatalf("cell %d is %q, expected %q", x, g.String(), s)
+		}
+	}
+	if s := st.lines[1].String(); s != "\u2764\ufe0f   " {
+		t.Fatalf("line is %q", s)
+	}
+	x, y := st.Cursor()
+	if x != 1 || y != 1 {
+		t.Fatalf("cursor at %d,%d, expected 1,1", x, y)
+	}
+}
+
+func TestInsertMode(t *testing.T) {
+	var st State
+	term, err := Create(&st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	term.Resize(6, 2)
+	tests := []struct {
+		in, expected string
+	}{
+		{"abcdef\033[1;2H\033[4hXY", "aXYbcd"},
+		// a wide character pushed past the margin is dropped whole
+		{"\033[4labcd世\033[1;1H\033[4hX", "Xabcd "},
+		// inserting inside a wide character blanks its first half
+		{"\033[4lab世cd\033[1;4H\033[4hX", "ab X c"},
+		{"\033[4lab世cd\033[1;1H\033[4h界", "界ab世"},
+		// inserting at the last column while a wrap is pending
+		{"\033[4labcdef\033[4hX", "abcdef"},
+	}
+	for _, test := range tests {
+		term.Write([]byte("\033[H\033[2J" + test.in))
+		if actual := st.lines[0].String(); actual != test.expected {
+			t.Fatalf("%q: got %q, expected %q", test.in, actual, test.expected)
+		}
+	}
+}
+
+func TestAttrs(t *testing.T) {
+	var st State
+	term, err := Create(&st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		in       string
+		expected Attr
+	}{
+		{"\033[1;2;3m", AttrBold | AttrFaint | AttrItalic},
+		{"\033[1;2;22m", 0},
+		{"\033[4;21m", AttrDoubleUnderline},
+		{"\033[21;4m", AttrUnderline},
+		{"\033[21;24m", 0},
+		{"\033[8;9;53m", AttrInvisible | AttrStrike | AttrOverline},
+		{"\033[8;9;53;28;29;55m", 0},
+		{"\033[5;7;9m\033[m", 0},
+		// line drawing is not a public attribute
+		{"\033(0\033[7m", AttrReverse},
+	}
+	for _, test := range tests {
+		term.Write([]byte("\033[H\033[m\033(B" + test.in + "q"))
+		if actual := st.lines[0][0].Attrs(); actual != test.expected {
+			t.Fatalf("%q: got %b, expected %b", test.in, actual, test.expected)
+		}
+	}
+}
+
+func TestUnderlineStyles(t *testing.T) {
+	var st State
+	term, err := Create(&st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		in     string
+		attrs  Attr
+		fg, ul Color
+	}{
+		{"\033[4:3m", AttrCurlyUnderline, DefaultFG, DefaultUL},
+		{"\033[4:3;4:4m", AttrDottedUnderline, DefaultFG, DefaultUL},
+		{"\033[4:5;1m", AttrDashedUnderline | AttrBold, DefaultFG, DefaultUL},
+		{"\033[4:3m\033[4:0m", 0, DefaultFG, DefaultUL},
+		{"\033[21m\033[4m", AttrUnderline, DefaultFG, DefaultUL},
+		{"\033[4:2;58:5:9m", AttrDoubleUnderline, DefaultFG, 9},
+		{"\033[58;2;1;2;3m", 0, DefaultFG, RGB(1, 2, 3)},
+		{"\033[58:2::1:2:3;38:2:4:5:6m", 0, RGB(4, 5, 6), RGB(1, 2, 3)},
+		{"\033[58:5:9;59m", 0, DefaultFG, DefaultUL},
+		{"\033[58:5:9;0m", 0, DefaultFG, DefaultUL},
+	}
+	for _, test := range tests {
+		term.Write([]byte("\033[H\033[m" + test.in + "x"))
+		g := st.lines[0][0]
+		if g.Attrs() != test.attrs || g.Fg != test.fg || g.Ul != test.ul {
+			t.Fatalf("%q: got %b %x %x", test.in, g.Attrs(), g.Fg, g.Ul)
+		}
+	}
+}
+
+func TestCursorStyle(t *testing.T) {
+	var st State
+	term, err := Create(&st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		in    string
+		shape CursorShape
+		blink bool
+	}{
+		{"\033[6 q", CursorBar, false},
+		{"\033[3 q", CursorUnderline, true},
+		{"\033[2 q", CursorBlock, false},
+		{"\033[5 q\033[ q", CursorBlock, true},
+		// not DECSCUSR without the intermediate space
+		{"\033[4 q\033[6q", CursorUnderline, false},
+		{"\033[4 q\033c", CursorBlock, true},
+	}
+	for _, test := range tests {
+		st.Lock()
+		st.Unlock()
+		term.Write([]byte(test.in))
+		shape, blink := st.CursorStyle()
+		if shape != test.shape || blink != test.blink || !st.Changed(ChangedCursorStyle) {
+			t.Fatalf("%q: got %d %v", test.in, shape, blink)
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	var st State
+	term, err := Create(&st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	term.Write([]byte("\033[24;80Hprimary\033[?1049h\033[24;80Halt\033c"))
+	if st.Mode(ModeAltScreen) {
+		t.Fatal("alt screen still shown after reset")
+	}
+	if s := st.String(); strings.TrimSpace(s) != "" {
+		t.Fatalf("screen not cleared: %q", s)
+	}
+	term.Write([]byte("\033[?1049h"))
+	if s := st.String(); strings.TrimSpace(s) != "" {
+		t.Fatalf("alt screen not cleared: %q", s)
+	}
+}
@@ -0,0 +1,213 @@
+//go:build linux || darwin || dragonfly || solaris || openbsd || netbsd || freebsd
+// +build linux darwin dragonfly solaris openbsd netbsd freebsd
+
+package vttest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/creack/pty"
+	"github.com/fdidron/vt10x"
+)
+
+// Console is a command running in a pseudo terminal emulated by vt10x.
+type Console struct {
+	cmd   *exec.Cmd
+	pty   *os.File
+	vt    *vt10x.VT
+	state vt10x.State
+
+	updated chan struct{} // signaled after output is parsed
+	done    chan struct{} // closed when the output ends
+
+	waitOnce sync.Once
+	waitErr  error
+}
+
+// Start starts cmd in a pseudo terminal of cols columns and rows rows.
+// The size is set before the command starts.
+func Start(cmd *exec.Cmd, cols, rows int) (*Console, error) {
+	f, err := pty.StartWithSize(cmd, &pty.Winsize{Cols: uint16(cols), Rows: uint16(rows)})
+	if err != nil {
+		return nil, err
+	}
+	c := &Console{
+		cmd:     cmd,
+		pty:     f,
+		updated: make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	c.vt, err = vt10x.Create(&c.state, f)
+	if err != nil {
+		f.Close()
+		cmd.Process.Kill()
+		return nil, err
+	}
+	c.vt.Resize(cols, rows)
+	go c.parse()
+	return c, nil
+}
+
+func (c *Console) parse() {
+	defer close(c.done)
+	for {
+		err := c.vt.Parse()
+		select {
+		case c.updated <- struct{}{}:
+		default:
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// State returns the emulated terminal. Lock it while reading it.
+func (c *Console) State() *vt10x.State {
+	return &c.state
+}
+
+// Send writes s to the command as typed input.
+func (c *Console) Send(s string) error {
+	_, err := c.pty.Write([]byte(s))
+	return err
+}
+
+// SendKey writes the encoding of a key press to the command.
+func (c *Console) SendKey(ev vt10x.KeyEvent) error {
+	return c.vt.SendKey(ev)
+}
+
+// Screen returns the text of the screen, one row per line.
+func (c *Console) Screen() string {
+	c.state.Lock()
+	defer c.state.Unlock()
+	return c.state.String()
+}
+
+func (c *Console) snapshot() *vt10x.Snapshot {
+	c.state.Lock()
+	defer c.state.Unlock()
+	return c.state.Snapshot()
+}
+
+// AssertGolden compares the screen with the golden file at path, as the
+// AssertGolden function.
+func (c *Console) AssertGolden(t testing.TB, path string, opts GoldenOptions) {
+	t.Helper()
+	c.state.Lock()
+	defer c.state.Unlock()
+	AssertGolden(t, &c.state, path, opts)
+}
+
+// WaitForText waits until text appears on a row of the screen.
+func (c *Console) WaitForText(text string, timeout time.Duration) error {
+	return c.wait(fmt.Sprintf("text %q", text), timeout, func(screen string) bool {
+		return strings.Contains(screen, text)
+	})
+}
+
+// WaitForRegexp waits until re matches the screen, with rows separated by
+// newlines, and returns the match and its submatches.
+func (c *Console) WaitForRegexp(re *regexp.Regexp, timeout time.Duration) ([]string, error) {
+	var m []string
+	err := c.wait(fmt.Sprintf("regexp %q", re), timeout, func(screen string) bool {
+		m = re.FindStringSubmatch(screen)
+		return m != nil
+	})
+	return m, err
+}
+
+// WaitForStable waits until the screen, cursor and modes have not changed
+// for d, or the output ended.
+func (c *Console) WaitForStable(d, timeout time.Duration) error {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	quiet := time.NewTimer(d)
+	defer quiet.Stop()
+	last := c.snapshot()
+	for {
+		select {
+		case <-c.updated:
+			s := c.snapshot()
+			if diff := vt10x.Diff(last, s); !diff.Empty() {
+				last = s
+				if !quiet.Stop() {
+					<-quiet.C
+				}
+				quiet.Reset(d)
+			}
+		case <-quiet.C:
+			return nil
+		case <-c.done:
+			return nil
+		case <-deadline.C:
+			return c.failure(fmt.Sprintf("timed out after %v waiting for the screen to be stable for %v", timeout, d))
+		}
+	}
+}
+
+// wait calls match with the screen after each update until it returns
+// true.
+func (c *Console) wait(what string, timeout time.Duration, match func(screen string) bool) error {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		if match(c.Screen()) {
+			return nil
+		}
+		select {
+		case <-c.updated:
+		case <-c.done:
+			if match(c.Screen()) {
+				return nil
+			}
+			return c.failure("output ended while waiting for " + what)
+		case <-deadline.C:
+			return c.failure(fmt.Sprintf("timed out after %v waiting for %s", timeout, what))
+		}
+	}
+}
+
+// failure returns an error with msg and a dump of the screen.
+func (c *Console) failure(msg string) error {
+	s := c.snapshot()
+	cols, rows := s.Size()
+	cx, cy := s.Cursor()
+	var b strings.Builder
+	fmt.Fprintf(&b, "vttest: %s\nscreen (%dx%d, cursor at %d,%d):", msg, cols, rows, cx, cy)
+	for y := 0; y < rows; y++ {
+		fmt.Fprintf(&b, "\n\t|%s", strings.TrimRight(s.Line(y).String(), " "))
+	}
+	return errors.New(b.String())
+}
+
+// Wait waits for the command to exit and its output to be parsed, and
+// returns its exit error.
+func (c *Console) Wait() error {
+	c.waitOnce.Do(func() {
+		c.waitErr = c.cmd.Wait()
+	})
+	<-c.done
+	return c.waitErr
+}
+
+// Close kills the command if it is still running and releases the
+// pseudo terminal.
+func (c *Console) Close() error {
+	c.cmd.Process.Kill()
+	c.waitOnce.Do(func() {
+		c.waitErr = c.cmd.Wait()
+	})
+	err := c.pty.Close()
+	<-c.done
+	return err
+}
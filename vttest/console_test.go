@@ -0,0 +1,56 @@
+//go:build linux || darwin || dragonfly || solaris || openbsd || netbsd || freebsd
+// +build linux darwin dragonfly solaris openbsd netbsd freebsd
+
+package vttest
+
+import (
+	"os/exec"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fdidron/vt10x"
+)
+
+func TestConsole(t *testing.T) {
+	c, err := Start(exec.Command("sh", "-c", `stty size; read x; echo "got $x"; read y; echo "then $y"`), 30, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.WaitForText("5 30", 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	c.Send("abc\r")
+	m, err := c.WaitForRegexp(regexp.MustCompile(`got (\w+)`), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m[1] != "abc" {
+		t.Fatalf("unexpected match %q", m)
+	}
+	if err := c.WaitForStable(50*time.Millisecond, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	// the error shows the screen
+	err = c.WaitForText("missing", 100*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "timed out") || !strings.Contains(err.Error(), "\t|got abc") {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	c.SendKey(vt10x.KeyEvent{Key: vt10x.KeyRune, Runes: []rune("x")})
+	c.SendKey(vt10x.KeyEvent{Key: vt10x.KeyEnter})
+	if err := c.WaitForText("then x", 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	err = c.WaitForText("missing", time.Second)
+	if err == nil || !strings.Contains(err.Error(), "output ended") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+/*
+Package vttest drives interactive terminal programs in tests. A Console
+runs a command in a pseudo terminal of a fixed size, emulated with vt10x,
+and waits for the rendered screen, rather than the raw output, to match
+expectations:
+
+	c, err := vttest.Start(exec.Command("top"), 80, 24)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.WaitForText("load average", 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	c.Send("q")
+
+Errors returned by the Wait methods include a dump of the screen.
+*/
+package vttest
@@ -0,0 +1,254 @@
+package vttest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fdidron/vt10x"
+)
+
+// GoldenOptions control FormatScreen and AssertGolden.
+type GoldenOptions struct {
+	// Attrs adds the attribute layer: each row is followed, after a "|",
+	// by one code per cell naming its colors and attributes, and a legend
+	// of the codes ends the screen. Cells with default colors and no
+	// attributes have a blank code.
+	Attrs bool
+	// Update makes AssertGolden rewrite the golden file instead of
+	// comparing with it, as does setting VTTEST_UPDATE=1. Tests defining
+	// an -update flag can pass its value.
+	Update bool
+}
+
+// codes of the styles of the attribute layer, in order of appearance
+const styleCodes = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// FormatScreen returns the screen of st in the format of golden files.
+// Without attributes it is the text of the rows, without trailing blanks.
+// The caller holds the lock of st.
+func FormatScreen(st *vt10x.State, opts GoldenOptions) string {
+	if !opts.Attrs {
+		return st.String() + "\n"
+	}
+	codes := make(map[string]byte)
+	var legend []string
+	var b strings.Builder
+	for _, l := range st.Lines() {
+		var attrs []byte
+		for _, g := range l {
+			if g.Width() != 0 {
+				b.WriteString(g.String())
+			}
+			s := describeStyle(g)
+			if s == "" {
+				attrs = append(attrs, ' ')
+				continue
+			}
+			c, ok := codes[s]
+			if !ok {
+				c = '*'
+				if len(legend) < len(styleCodes) {
+					c = styleCodes[len(legend)]
+				}
+				codes[s] = c
+				legend = append(legend, fmt.Sprintf("%c: %s", c, s))
+			}
+			attrs = append(attrs, c)
+		}
+		b.WriteString("|")
+		b.WriteString(strings.TrimRight(string(attrs), " "))
+		b.WriteString("\n")
+	}
+	if len(legend) > 0 {
+		b.WriteString("--\n")
+		b.WriteString(strings.Join(legend, "\n"))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+var attrNames = []struct {
+	attr vt10x.Attr
+	name string
+}{
+	{vt10x.AttrBold, "bold"},
+	{vt10x.AttrFaint, "faint"},
+	{vt10x.AttrItalic, "italic"},
+	{vt10x.AttrUnderline, "underline"},
+	{vt10x.AttrDoubleUnderline, "double-underline"},
+	{vt10x.AttrCurlyUnderline, "curly-underline"},
+	{vt10x.AttrDottedUnderline, "dotted-underline"},
+	{vt10x.AttrDashedUnderline, "dashed-underline"},
+	{vt10x.AttrBlink, "blink"},
+	{vt10x.AttrReverse, "reverse"},
+	{vt10x.AttrInvisible, "invisible"},
+	{vt10x.AttrStrike, "strike"},
+	{vt10x.AttrOverline, "overline"},
+}
+
+// describeStyle returns the colors and attributes of g, or an empty string
+// for the default style.
+func describeStyle(g vt10x.Glyph) string {
+	var s []string
+	for _, c := range []struct {
+		name string
+		c    vt10x.Color
+		def  vt10x.Color
+	}{
+		{"fg", g.Fg, vt10x.DefaultFG},
+		{"bg", g.Bg, vt10x.DefaultBG},
+		{"ul", g.Ul, vt10x.DefaultUL},
+	} {
+		if c.c != c.def {
+			s = append(s, c.name+"="+describeColor(c.c))
+		}
+	}
+	for _, a := range attrNames {
+		if g.Attrs()&a.attr != 0 {
+			s = append(s, a.name)
+		}
+	}
+	return strings.Join(s, " ")
+}
+
+func describeColor(c vt10x.Color) string {
+	switch {
+	case c.IsRGB():
+		r, g, b := c.RGB()
+		return fmt.Sprintf("#%02x%02x%02x", r, g, b)
+	case c == vt10x.DefaultFG:
+		return "default-fg"
+	case c == vt10x.DefaultBG:
+		return "default-bg"
+	}
+	return fmt.Sprint(uint32(c))
+}
+
+// AssertGolden compares the screen of st, formatted with FormatScreen,
+// with the golden file at path and fails t with a unified diff if they
+// differ. With opts.Update or VTTEST_UPDATE=1 in the environment it
+// rewrites the golden file instead.
+// The caller holds the lock of st.
+func AssertGolden(t testing.TB, st *vt10x.State, path string, opts GoldenOptions) {
+	t.Helper()
+	actual := FormatScreen(st, opts)
+	if opts.Update || os.Getenv("VTTEST_UPDATE") == "1" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(actual), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	expected, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		t.Fatalf("golden file %s does not exist, run the test with VTTEST_UPDATE=1 to create it. Screen:\n%s", path, actual)
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	if string(expected) != actual {
+		t.Fatalf("screen differs from golden file %s, run the test with VTTEST_UPDATE=1 to accept it:\n%s",
+			path, unifiedDiff(splitLines(string(expected)), splitLines(actual), path, "screen"))
+	}
+}
+
+func splitLines(s string) []string {
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// unifiedDiff returns the differences between a and b as a unified diff
+// with three lines of context.
+func unifiedDiff(a, b []string, nameA, nameB string) string {
+	// longest common subsequence lengths of the suffixes
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	// edit script: ' ', '-' or '+' per line
+	type edit struct {
+		op   byte
+		i, j int // lines of a and b before the edit
+		text string
+	}
+	var edits []edit
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{' ', i, j, a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{'-', i, j, a[i]})
+			i++
+		default:
+			edits = append(edits, edit{'+', i, j, b[j]})
+			j++
+		}
+	}
+
+	const context = 3
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", nameA, nameB)
+	for k := 0; k < len(edits); {
+		if edits[k].op == ' ' {
+			k++
+			continue
+		}
+		// extend the hunk while changes are close enough to share context
+		start := max(0, k-context)
+		end := k
+		for n := k; n < len(edits); n++ {
+			if edits[n].op != ' ' {
+				end = n + 1
+			} else if n-end >= 2*context {
+				break
+			}
+		}
+		end = min(len(edits), end+context)
+		var na, nb int
+		for _, e := range edits[start:end] {
+			if e.op != '+' {
+				na++
+			}
+			if e.op != '-' {
+				nb++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n", edits[start].i+1, na, edits[start].j+1, nb)
+		for _, e := range edits[start:end] {
+			fmt.Fprintf(&out, "%c%s\n", e.op, e.text)
+		}
+		k = end
+	}
+	return out.String()
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,97 @@
+package vttest
+
+import (
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fdidron/vt10x"
+)
+
+// the package does not register a flag of its own that this would redefine
+var update = flag.Bool("update", false, "rewrite golden files")
+
+func newState(t *testing.T, cols, rows int, out string) *vt10x.State {
+	var st vt10x.State
+	term, err := vt10x.Create(&st, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	term.Resize(cols, rows)
+	term.Write([]byte(out))
+	return &st
+}
+
+func TestAssertGolden(t *testing.T) {
+	st := newState(t, 20, 4, "\033[1;31mError:\033[0m not found\r\n"+
+		"\033[7m世界\033[0m \033[4:3;58;2;255;0;0mtypo\033[0m\r\n\033[38;2;1;2;3;48;5;200mrgb\033[m")
+	AssertGolden(t, st, "testdata/text.golden", GoldenOptions{Update: *update})
+	AssertGolden(t, st, "testdata/attrs.golden", GoldenOptions{Attrs: true, Update: *update})
+
+	path := filepath.Join(t.TempDir(), "new", "screen.golden")
+	AssertGolden(t, st, path, GoldenOptions{Update: true})
+	var tb fakeTB
+	AssertGolden(&tb, st, path, GoldenOptions{})
+	if tb.failure != "" {
+		t.Fatalf("unexpected failure after update:\n%s", tb.failure)
+	}
+}
+
+// fakeTB records the failure of an assertion.
+type fakeTB struct {
+	testing.TB
+	failure string
+}
+
+func (t *fakeTB) Helper() {}
+
+// Fatalf keeps the first failure, as the real one stops the test.
+func (t *fakeTB) Fatalf(format string, args ...interface{}) {
+	if t.failure == "" {
+		t.failure = fmt.Sprintf(format, args...)
+	}
+}
+
+func TestAssertGoldenMismatch(t *testing.T) {
+	st := newState(t, 10, 2, "hello\r\nworld")
+	var tb fakeTB
+	AssertGolden(&tb, st, "testdata/mismatch.golden", GoldenOptions{})
+	expected := "--- testdata/mismatch.golden\n+++ screen\n@@ -1,2 +1,2 @@\n hello\n-there\n+world\n"
+	if !strings.HasSuffix(tb.failure, expected) {
+		t.Fatalf("unexpected failure:\n%s", tb.failure)
+	}
+
+	tb.failure = ""
+	AssertGolden(&tb, st, "testdata/missing.golden", GoldenOptions{})
+	if !strings.Contains(tb.failure, "does not exist") {
+		t.Fatalf("unexpected failure:\n%s", tb.failure)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	a := strings.Split("a b c d e f g h i j k l m", " ")
+	b := strings.Split("a b x d e f g h i j k l n o", " ")
+	expected := `--- a
++++ b
+@@ -1,6 +1,6 @@
+ a
+ b
+-c
++x
+ d
+ e
+ f
+@@ -10,4 +10,5 @@
+ j
+ k
+ l
+-m
++n
++o
+`
+	if actual := unifiedDiff(a, b, "a", "b"); actual != expected {
+		t.Fatalf("got\n%s\nexpected\n%s", actual, expected)
+	}
+}